@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/csvimport"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+// runImportCSV implements `api import-csv [-org slug] [-dry-run] games.csv`.
+func runImportCSV(ctx context.Context, repo *repository.Repository, args []string) error {
+	fs := flag.NewFlagSet("import-csv", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "check the file and report problems without importing anything")
+	orgSlug := fs.String("org", repository.DefaultOrganization, "organization to import the games into")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api import-csv [-org slug] [-dry-run] games.csv")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	org, err := repo.GetOrganizationBySlug(ctx, *orgSlug)
+	if err != nil {
+		return fmt.Errorf("organization %q: %w", *orgSlug, err)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rows, rowErrors, err := csvimport.Parse(f)
+	if err != nil {
+		return err
+	}
+
+	report := csvimport.NewReport(rows, rowErrors, *dryRun)
+	ctx = repository.WithOrganization(repository.WithActor(ctx, "cli"), org)
+	if err := repo.ImportHistoricalGames(ctx, rows, *dryRun, report); err != nil {
+		return err
+	}
+	report.SortErrors()
+
+	for _, e := range report.Errors {
+		fmt.Printf("line %d: %s\n", e.Line, e.Message)
+	}
+	for _, name := range report.PlayersCreated {
+		if report.DryRun || len(report.Errors) > 0 {
+			fmt.Printf("would create player %q\n", name)
+		} else {
+			fmt.Printf("created player %q\n", name)
+		}
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		return fmt.Errorf("%d of %d rows have problems; nothing was imported", len(report.Errors), report.Rows)
+	case report.DryRun:
+		fmt.Printf("%d rows look good; run without -dry-run to import them\n", report.Rows)
+	default:
+		fmt.Printf("imported %d games\n", report.GamesImported)
+	}
+	return nil
+}
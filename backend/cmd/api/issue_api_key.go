@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+// runIssueAPIKey issues a key from the command line, which is how the first
+// admin key of an organization is made.
+func runIssueAPIKey(ctx context.Context, repo *repository.Repository, args []string) error {
+	fs := flag.NewFlagSet("issue-api-key", flag.ExitOnError)
+	orgSlug := fs.String("org", repository.DefaultOrganization, "organization the key is for")
+	role := fs.String("role", repository.RoleAdmin, "role of the key: viewer, recorder or admin")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api issue-api-key [-org slug] [-role role] name")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || !slices.Contains(repository.Roles, *role) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	org, err := repo.GetOrganizationBySlug(ctx, *orgSlug)
+	if err != nil {
+		return fmt.Errorf("organization %q: %w", *orgSlug, err)
+	}
+
+	ctx = repository.WithOrganization(repository.WithActor(ctx, "cli"), org)
+	key, err := repo.CreateAPIKey(ctx, models.APIKeyRequest{Name: fs.Arg(0), Role: *role})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("issued %s key %q for %s; it will not be shown again:\n%s\n", key.Role, key.Name, org.Slug, key.Key)
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/handlers"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/logging"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/metrics"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+// newRouter routes every endpoint to handler, with the roles each needs.
+func newRouter(handler *handlers.Handler, exporter *metrics.Exporter, idempotencyTTL time.Duration) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(logging.Requests)
+	r.Use(exporter.Middleware)
+	r.Use(logging.Recoverer)
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"http://localhost:5173", "http://localhost:3000"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "If-Match", "X-API-Key", "X-Organization", "X-Request-Id"},
+		ExposedHeaders:   []string{"ETag", "X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+	r.Use(handler.Authenticate)
+
+	viewer := handler.RequireRole(repository.RoleViewer)
+	recorder := handler.RequireRole(repository.RoleRecorder)
+	admin := handler.RequireRole(repository.RoleAdmin)
+
+	// Everything below is scoped to one organization, chosen by the
+	// /api/orgs/{org} prefix or the X-Organization header.
+	organizationRoutes := func(r chi.Router) {
+		r.Use(handler.Organization)
+
+		r.Group(func(r chi.Router) {
+			r.Use(viewer)
+			r.Get("/organization", handler.GetOrganization)
+			r.Get("/players", handler.ListPlayers)
+			r.Get("/players/{id}", handler.GetPlayer)
+			r.Get("/players/{id}/stats", handler.GetPlayerStats)
+			r.Get("/players/{id}/head-to-head", handler.GetPlayerHeadToHead)
+			r.Get("/players/{id}/rating-history", handler.GetPlayerRatingHistory)
+			r.Get("/players/{id}/recent-games", handler.GetPlayerRecentGames)
+			r.Get("/games", handler.ListGames)
+			r.Post("/games/parse", handler.ParseGame)
+			r.Get("/games/pending", handler.ListPendingGames)
+			// Only the losing team's players and admins get past these.
+			r.Post("/games/pending/{id}/confirm", handler.ConfirmPendingGame)
+			r.Post("/games/pending/{id}/reject", handler.RejectPendingGame)
+			r.Get("/games/{id}", handler.GetGame)
+			r.Get("/games/{id}/disputes", handler.ListGameDisputes)
+			// Only the game's players and admins get past this.
+			r.Post("/games/{id}/disputes", handler.OpenDispute)
+			r.Get("/live-games", handler.ListLiveGames)
+			r.Get("/live-games/{id}", handler.GetLiveGame)
+			r.Get("/live-games/{id}/stream", handler.StreamLiveGame)
+			r.Get("/leaderboard", handler.Leaderboard)
+			r.Get("/stream", handler.StreamChanges)
+			r.Get("/tables", handler.ListTables)
+			r.Get("/tables/{id}", handler.GetTable)
+			r.Get("/tables/{id}/stats", handler.GetTableStats)
+			r.Get("/sides/advantage", handler.SideAdvantage)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(recorder)
+			r.With(handler.Idempotent(idempotencyTTL)).Post("/players", handler.CreatePlayer)
+			r.With(handler.Idempotent(idempotencyTTL)).Post("/games", handler.CreateGame)
+			r.Post("/live-games", handler.StartLiveGame)
+			r.Delete("/live-games/{id}", handler.AbandonLiveGame)
+			r.Post("/live-games/{id}/goals", handler.AddLiveGoal)
+			r.Delete("/live-games/{id}/goals/last", handler.UndoLiveGoal)
+			r.Post("/live-games/{id}/finish", handler.FinishLiveGame)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(admin)
+			r.Put("/organization", handler.UpdateOrganization)
+			r.Delete("/players/{id}", handler.DeletePlayer)
+			r.Put("/games/{id}", handler.UpdateGame)
+			r.Delete("/games/{id}", handler.DeleteGame)
+			r.Post("/tables", handler.CreateTable)
+			r.Put("/tables/{id}", handler.UpdateTable)
+			r.Get("/admin/audit", handler.AuditLog)
+			r.Get("/admin/events", handler.ListEvents)
+			r.Post("/admin/rebuild", handler.RebuildProjections)
+			r.Get("/admin/export", handler.Export)
+			r.Post("/admin/import", handler.Import)
+			r.Post("/admin/import/csv", handler.ImportCSV)
+			r.Get("/admin/webhooks", handler.ListWebhooks)
+			r.Post("/admin/webhooks", handler.CreateWebhook)
+			r.Get("/admin/webhooks/{id}", handler.GetWebhook)
+			r.Delete("/admin/webhooks/{id}", handler.DeleteWebhook)
+			r.Get("/admin/webhooks/{id}/deliveries", handler.ListWebhookDeliveries)
+			r.Post("/admin/webhooks/{id}/deliveries/{deliveryID}/redeliver", handler.RedeliverWebhook)
+			r.Get("/admin/chat-handles", handler.ListChatHandles)
+			r.Put("/admin/chat-handles", handler.SetChatHandle)
+			r.Delete("/admin/chat-handles/{handle}", handler.DeleteChatHandle)
+			r.Get("/admin/api-keys", handler.ListAPIKeys)
+			r.Post("/admin/api-keys", handler.CreateAPIKey)
+			r.Delete("/admin/api-keys/{id}", handler.RevokeAPIKey)
+			r.Get("/admin/users", handler.ListUsers)
+			r.Post("/admin/users", handler.CreateUser)
+			r.Put("/admin/users/{id}", handler.UpdateUser)
+			r.Delete("/admin/users/{id}", handler.DeleteUser)
+			r.Get("/admin/disputes", handler.ListDisputes)
+			r.Post("/admin/disputes/{id}/resolve", handler.ResolveDispute)
+		})
+
+		// Players signed in as themselves can rename themselves.
+		r.With(handler.RequirePlayerOrRole(repository.RoleAdmin)).Put("/players/{id}", handler.UpdatePlayer)
+
+		r.Post("/auth/login", handler.Login)
+		r.Post("/auth/logout", handler.Logout)
+		r.Get("/auth/me", handler.CurrentUser)
+		r.Put("/auth/password", handler.ChangePassword)
+
+		// Slack signs its requests instead of sending an API key.
+		r.Post("/integrations/slack/command", handler.SlackCommand)
+	}
+
+	// Metrics are labelled with every organization's slug, so only the
+	// operator may scrape them.
+	r.With(handler.RequireOperator).Get("/metrics", exporter.ServeHTTP)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/health", handler.Health)
+		r.With(handler.RequireOperator).Get("/organizations", handler.ListOrganizations)
+		r.With(handler.RequireOperator).Post("/organizations", handler.CreateOrganization)
+		r.Route("/orgs/{org}", organizationRoutes)
+		r.Group(organizationRoutes)
+	})
+
+	return r
+}
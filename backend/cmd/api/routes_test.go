@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/handlers"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/metrics"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/testdb"
+)
+
+const testOperatorKey = "operator-secret"
+
+// testServer serves the full router over a fresh database, with the
+// default organization and a second one called "other".
+type testServer struct {
+	*httptest.Server
+	repo  *repository.Repository
+	home  context.Context
+	other context.Context
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	pool := testdb.New(t)
+	repo := repository.New(pool)
+	handler := handlers.New(repo, handlers.Config{
+		SessionTTL:  time.Hour,
+		OperatorKey: testOperatorKey,
+	})
+	srv := httptest.NewServer(newRouter(handler, metrics.New(pool, repo), time.Hour))
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	home, err := repo.GetOrganizationBySlug(ctx, repository.DefaultOrganization)
+	if err != nil {
+		t.Fatalf("loading default organization: %v", err)
+	}
+	other, err := repo.CreateOrganization(ctx, models.CreateOrganizationRequest{
+		Slug: "other",
+		Name: "Other",
+		OrganizationSettings: models.OrganizationSettings{
+			RatingSystem:      "elo",
+			KFactor:           32,
+			GameFormats:       []string{"singles", "doubles"},
+			ConfirmationHours: 48,
+			AnonymousRole:     repository.RoleNone,
+		},
+	})
+	if err != nil {
+		t.Fatalf("creating organization: %v", err)
+	}
+	return &testServer{
+		Server: srv,
+		repo:   repo,
+		home:   repository.WithOrganization(ctx, home),
+		other:  repository.WithOrganization(ctx, other),
+	}
+}
+
+// adminKey issues an admin API key in the organization of ctx.
+func (s *testServer) adminKey(t *testing.T, ctx context.Context) string {
+	t.Helper()
+	key, err := s.repo.CreateAPIKey(ctx, models.APIKeyRequest{Name: "admin", Role: repository.RoleAdmin})
+	if err != nil {
+		t.Fatalf("creating API key: %v", err)
+	}
+	return key.Key
+}
+
+// do sends a request with key as its bearer token, if any, and returns the
+// response status.
+func (s *testServer) do(t *testing.T, method, path, key, body string, header http.Header) int {
+	t.Helper()
+	return s.send(t, method, path, key, body, header).StatusCode
+}
+
+// send is do returning the whole response, with its body already closed.
+func (s *testServer) send(t *testing.T, method, path, key, body string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// TestRoutesKeepOrganizationsApart checks that an admin of one organization
+// can neither use its key on another's routes nor reach another's records
+// by ID through its own.
+func TestRoutesKeepOrganizationsApart(t *testing.T) {
+	s := newTestServer(t)
+	homeKey := s.adminKey(t, s.home)
+	otherKey := s.adminKey(t, s.other)
+
+	player, err := s.repo.CreatePlayer(s.home, "Alice")
+	if err != nil {
+		t.Fatalf("creating player: %v", err)
+	}
+	playerPath := "/players/" + strconv.Itoa(player.ID)
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{"GET", "/admin/api-keys", ""},
+		{"GET", "/admin/audit", ""},
+		{"POST", "/players", `{"name":"Mallory"}`},
+		{"PUT", "/organization", `{"name":"Taken"}`},
+		{"DELETE", playerPath, ""},
+	} {
+		if status := s.do(t, tc.method, "/api/orgs/default"+tc.path, otherKey, tc.body, nil); status != http.StatusForbidden {
+			t.Errorf("%s /api/orgs/default%s with another organization's key = %d, want 403", tc.method, tc.path, status)
+		}
+		header := http.Header{"X-Organization": {repository.DefaultOrganization}}
+		if status := s.do(t, tc.method, "/api"+tc.path, otherKey, tc.body, header); status != http.StatusForbidden {
+			t.Errorf("%s /api%s for default with another organization's key = %d, want 403", tc.method, tc.path, status)
+		}
+	}
+
+	// The default organization lets anyone read it; the other one does not,
+	// and neither lends anonymous callers any more than it chose to.
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/api/orgs/default/players", "", http.StatusOK},
+		{"GET", "/api/orgs/other/players", "", http.StatusUnauthorized},
+		{"GET", "/api/orgs/other/games/pending", "", http.StatusUnauthorized},
+		{"GET", "/api/orgs/other/stream", "", http.StatusUnauthorized},
+		{"POST", "/api/orgs/default/players", `{"name":"Mallory"}`, http.StatusUnauthorized},
+	} {
+		if status := s.do(t, tc.method, tc.path, "", tc.body, nil); status != tc.want {
+			t.Errorf("anonymous %s %s = %d, want %d", tc.method, tc.path, status, tc.want)
+		}
+	}
+
+	if status := s.do(t, "GET", "/api/orgs/other"+playerPath, otherKey, "", nil); status != http.StatusNotFound {
+		t.Errorf("GET another organization's player = %d, want 404", status)
+	}
+	if status := s.do(t, "DELETE", "/api/orgs/other"+playerPath, otherKey, "", nil); status < 400 {
+		t.Errorf("DELETE another organization's player = %d, want an error", status)
+	}
+	if status := s.do(t, "GET", "/api/orgs/default"+playerPath, homeKey, "", nil); status != http.StatusOK {
+		t.Errorf("GET own player = %d, want 200", status)
+	}
+}
+
+// TestOrganizationsNeedOperatorKey checks that only the operator may list
+// and create organizations and read metrics, and that the operator key opens
+// nothing else.
+func TestOrganizationsNeedOperatorKey(t *testing.T) {
+	s := newTestServer(t)
+	adminKey := s.adminKey(t, s.home)
+	create := `{"slug":"new","name":"New","rating_system":"elo","k_factor":32,"game_formats":["singles"],"confirmation_hours":48}`
+
+	for _, tc := range []struct {
+		name, method, path, key, body string
+		want                          int
+	}{
+		{"anonymous list", "GET", "/api/organizations", "", "", http.StatusUnauthorized},
+		{"anonymous create", "POST", "/api/organizations", "", create, http.StatusUnauthorized},
+		{"admin list", "GET", "/api/organizations", adminKey, "", http.StatusForbidden},
+		{"admin create", "POST", "/api/organizations", adminKey, create, http.StatusForbidden},
+		{"operator list", "GET", "/api/organizations", testOperatorKey, "", http.StatusOK},
+		{"operator create", "POST", "/api/organizations", testOperatorKey, create, http.StatusCreated},
+		{"operator in organization", "GET", "/api/orgs/default/players", testOperatorKey, "", http.StatusForbidden},
+		{"anonymous metrics", "GET", "/metrics", "", "", http.StatusUnauthorized},
+		{"admin metrics", "GET", "/metrics", adminKey, "", http.StatusForbidden},
+		{"operator metrics", "GET", "/metrics", testOperatorKey, "", http.StatusOK},
+	} {
+		if status := s.do(t, tc.method, tc.path, tc.key, tc.body, nil); status != tc.want {
+			t.Errorf("%s: %s %s = %d, want %d", tc.name, tc.method, tc.path, status, tc.want)
+		}
+	}
+}
+
+// TestIdempotencyKeysFollowTheCaller checks that a retry is recognised
+// whichever way it names the organization, and that another client using the
+// same key is not handed the first client's response.
+func TestIdempotencyKeysFollowTheCaller(t *testing.T) {
+	s := newTestServer(t)
+	firstKey := s.adminKey(t, s.home)
+	secondKey := s.adminKey(t, s.home)
+	header := http.Header{"Idempotency-Key": {"create-alice"}}
+	body := `{"name":"Alice"}`
+
+	if resp := s.send(t, "POST", "/api/orgs/default/players", firstKey, body, header); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first request = %d, want 201", resp.StatusCode)
+	}
+	resp := s.send(t, "POST", "/api/players", firstKey, body, header)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry without the organization prefix = %d, replayed %q; want the stored 201", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+	}
+	resp = s.send(t, "POST", "/api/orgs/default/players", secondKey, `{"name":"Bob"}`, header)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("another client's request with the same key = %d, replayed %q; want a new 201", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+	}
+}
+
+// TestResolveDispute checks that only corrections carry a score, that they
+// must, and that deleting a game closes its dispute.
+func TestResolveDispute(t *testing.T) {
+	s := newTestServer(t)
+	adminKey := s.adminKey(t, s.home)
+
+	players := make([]int, 2)
+	for i, name := range []string{"Alice", "Bob"} {
+		player, err := s.repo.CreatePlayer(s.home, name)
+		if err != nil {
+			t.Fatalf("creating player: %v", err)
+		}
+		players[i] = player.ID
+	}
+	game, err := s.repo.CreateGame(s.home, models.CreateGameRequest{
+		GameType: "singles",
+		Teams: []models.CreateGameTeam{
+			{PlayerIDs: players[:1], Score: 10},
+			{PlayerIDs: players[1:], Score: 4},
+		},
+	})
+	if err != nil {
+		t.Fatalf("recording game: %v", err)
+	}
+	dispute, err := s.repo.OpenDispute(s.home, game.ID, "It was 10-6")
+	if err != nil {
+		t.Fatalf("opening dispute: %v", err)
+	}
+	resolve := "/api/orgs/default/admin/disputes/" + strconv.Itoa(dispute.ID) + "/resolve"
+
+	for _, body := range []string{
+		`{"resolution":"corrected"}`,
+		`{"resolution":"corrected","team1_score":10}`,
+		`{"resolution":"upheld","team1_score":10,"team2_score":6}`,
+		`{"resolution":"voided","goals":[]}`,
+	} {
+		if status := s.do(t, "POST", resolve, adminKey, body, nil); status != http.StatusBadRequest {
+			t.Errorf("resolving with %s = %d, want 400", body, status)
+		}
+	}
+
+	if status := s.do(t, "DELETE", "/api/orgs/default/games/"+strconv.Itoa(game.ID), adminKey, "", nil); status != http.StatusOK {
+		t.Fatalf("deleting disputed game = %d, want 200", status)
+	}
+	if status := s.do(t, "POST", resolve, adminKey, `{"resolution":"corrected","team1_score":10,"team2_score":6}`, nil); status != http.StatusConflict {
+		t.Errorf("resolving the dispute about a deleted game = %d, want 409", status)
+	}
+}
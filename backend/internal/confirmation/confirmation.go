@@ -0,0 +1,43 @@
+package confirmation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const pollInterval = time.Minute
+
+// Worker confirms games that have waited too long for the losing team to
+// confirm them.
+type Worker struct {
+	repo *repository.Repository
+}
+
+func NewWorker(repo *repository.Repository) *Worker {
+	return &Worker{repo: repo}
+}
+
+// Run confirms overdue games until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		confirmed, err := w.repo.ConfirmOverdueGames(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to confirm overdue games", "error", err)
+		}
+		if confirmed > 0 {
+			slog.InfoContext(ctx, "Confirmed overdue games", "count", confirmed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
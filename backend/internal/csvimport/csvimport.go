@@ -0,0 +1,189 @@
+package csvimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns expected in the header row. Second players are left blank for
+// singles games.
+var columns = []string{
+	"date", "game_type",
+	"team1_player1", "team1_player2",
+	"team2_player1", "team2_player2",
+	"team1_score", "team2_score",
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+type Team struct {
+	Players []string `json:"players"`
+	Score   int      `json:"score"`
+}
+
+type Row struct {
+	Line     int       `json:"line"`
+	PlayedAt time.Time `json:"played_at"`
+	GameType string    `json:"game_type"`
+	Teams    [2]Team   `json:"teams"`
+}
+
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type Report struct {
+	DryRun         bool       `json:"dry_run"`
+	Rows           int        `json:"rows"`
+	GamesImported  int        `json:"games_imported"`
+	PlayersCreated []string   `json:"players_created"`
+	Errors         []RowError `json:"errors"`
+}
+
+func NewReport(rows []Row, rowErrors []RowError, dryRun bool) *Report {
+	return &Report{
+		DryRun:         dryRun,
+		Rows:           len(rows) + len(rowErrors),
+		PlayersCreated: []string{},
+		Errors:         append([]RowError{}, rowErrors...),
+	}
+}
+
+// SortErrors orders problems by line so they read top to bottom like the
+// spreadsheet.
+func (r *Report) SortErrors() {
+	sort.SliceStable(r.Errors, func(i, j int) bool { return r.Errors[i].Line < r.Errors[j].Line })
+}
+
+// Parse reads a spreadsheet export. Rows that cannot be used are reported by
+// line number rather than aborting the whole file, so one pass shows every
+// problem.
+func Parse(r io.Reader) ([]Row, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, col := range columns {
+		if _, ok := index[col]; !ok {
+			return nil, nil, fmt.Errorf("missing column %q", col)
+		}
+	}
+
+	var rows []Row
+	var rowErrors []RowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		field := func(col string) string {
+			if i := index[col]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row, err := parseRow(field)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+func parseRow(field func(string) string) (Row, error) {
+	var row Row
+
+	playedAt, err := parseDate(field("date"))
+	if err != nil {
+		return row, err
+	}
+	// As with games recorded through the API, a game from the future would
+	// sort after every real game and change how they replay.
+	if playedAt.After(time.Now()) {
+		return row, fmt.Errorf("date %q is in the future", field("date"))
+	}
+	row.PlayedAt = playedAt
+
+	row.GameType = strings.ToLower(field("game_type"))
+	perTeam := map[string]int{"singles": 1, "doubles": 2}[row.GameType]
+	if perTeam == 0 {
+		return row, fmt.Errorf("game type must be 'singles' or 'doubles', got %q", field("game_type"))
+	}
+
+	seen := make(map[string]bool)
+	for t := range row.Teams {
+		prefix := fmt.Sprintf("team%d_", t+1)
+		for _, col := range []string{prefix + "player1", prefix + "player2"} {
+			name := field(col)
+			if name == "" {
+				continue
+			}
+			if seen[strings.ToLower(name)] {
+				return row, fmt.Errorf("player %q appears more than once", name)
+			}
+			seen[strings.ToLower(name)] = true
+			row.Teams[t].Players = append(row.Teams[t].Players, name)
+		}
+		if len(row.Teams[t].Players) != perTeam {
+			return row, fmt.Errorf("team %d needs %d player(s) for %s", t+1, perTeam, row.GameType)
+		}
+
+		score, err := strconv.Atoi(field(prefix + "score"))
+		if err != nil || score < 0 {
+			return row, fmt.Errorf("team %d score must be a non-negative number", t+1)
+		}
+		row.Teams[t].Score = score
+	}
+
+	if row.Teams[0].Score == row.Teams[1].Score {
+		return row, errors.New("scores are tied")
+	}
+	return row, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
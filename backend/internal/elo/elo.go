@@ -2,27 +2,113 @@ package elo
 
 import "math"
 
+// Side advantage is learned from results between two sides, shrunk towards
+// an even split as if sidePriorGames had already been played and split
+// evenly, and capped so a lopsided run of games cannot dominate ratings.
+const (
+	sidePriorGames   = 20
+	maxSideAdvantage = 100
+)
+
 const (
 	KFactor       = 32
 	InitialRating = 1500
 )
 
-func CalculateNewRatings(teamARating, teamBRating float64, teamAWon bool) (deltaA, deltaB int) {
+// Rating systems an organization can choose between. SystemMarginElo scales
+// the rating change by the goal difference, so a 10-0 win counts for more
+// than a 10-9 one.
+const (
+	SystemElo       = "elo"
+	SystemMarginElo = "margin_elo"
+)
+
+var Systems = []string{SystemElo, SystemMarginElo}
+
+// Calculator rates games using one rating system and K factor.
+type Calculator struct {
+	System  string
+	KFactor float64
+}
+
+// Default is the calculator used before ratings were configurable.
+var Default = Calculator{System: SystemElo, KFactor: KFactor}
+
+// Scaled returns c with its K factor multiplied by factor, for games that
+// should move ratings more or less than usual.
+func (c Calculator) Scaled(factor float64) Calculator {
+	c.KFactor *= factor
+	return c
+}
+
+// NewRatings returns the rating change for each team given their average
+// ratings and the final score. Team A only wins with the higher score.
+func (c Calculator) NewRatings(teamARating, teamBRating float64, scoreA, scoreB int) (deltaA, deltaB int) {
+	multiplier := 1.0
+	if c.System == SystemMarginElo {
+		multiplier = marginMultiplier(scoreA - scoreB)
+	}
+
 	expectedA := 1.0 / (1.0 + math.Pow(10, (teamBRating-teamARating)/400.0))
 	expectedB := 1 - expectedA
 
 	var actualA, actualB float64
-	if teamAWon {
+	if scoreA > scoreB {
 		actualA, actualB = 1.0, 0.0
 	} else {
 		actualA, actualB = 0.0, 1.0
 	}
 
-	deltaA = int(math.Round(KFactor * (actualA - expectedA)))
-	deltaB = int(math.Round(KFactor * (actualB - expectedB)))
+	k := c.KFactor * multiplier
+	deltaA = int(math.Round(k * (actualA - expectedA)))
+	deltaB = int(math.Round(k * (actualB - expectedB)))
 	return
 }
 
+// marginMultiplier follows the World Football Elo ratings: wins by one goal
+// count normally, by two half as much again, and by more on a sliding scale.
+func marginMultiplier(goalDifference int) float64 {
+	if goalDifference < 0 {
+		goalDifference = -goalDifference
+	}
+	switch {
+	case goalDifference <= 1:
+		return 1
+	case goalDifference == 2:
+		return 1.5
+	default:
+		return (11 + float64(goalDifference)) / 8
+	}
+}
+
+// SideAdvantage returns the rating points a side is worth against another,
+// given its wins and losses against that side.
+func SideAdvantage(wins, losses int) float64 {
+	p := (float64(wins) + sidePriorGames/2) / (float64(wins+losses) + sidePriorGames)
+	return math.Max(-maxSideAdvantage, math.Min(maxSideAdvantage, RatingOffset(p)))
+}
+
+// RatingOffset is the rating difference at which the stronger side is
+// expected to win with probability p. p is kept within [0.01, 0.99] so the
+// result stays finite.
+func RatingOffset(p float64) float64 {
+	p = math.Max(0.01, math.Min(0.99, p))
+	return 400 * math.Log10(p/(1-p))
+}
+
+// WinRateInterval is the Wilson score interval for a win rate of wins out of
+// games, at the confidence given by z (1.96 for 95%).
+func WinRateInterval(wins, games int, z float64) (low, high float64) {
+	if games == 0 {
+		return 0, 1
+	}
+	n := float64(games)
+	p := float64(wins) / n
+	centre := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Max(0, centre-margin), math.Min(1, centre+margin)
+}
+
 func AverageRating(ratings []int) float64 {
 	if len(ratings) == 0 {
 		return InitialRating
@@ -0,0 +1,349 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+const (
+	Format        = "foosball-elo-export"
+	FormatVersion = 1
+)
+
+// Document is the portable representation of a whole database. Games carry
+// their participants; the event ledger is included so projections can still
+// be rebuilt after an import. RatingSystem and KFactor are the exporting
+// organization's, which its ratings were computed with; exports made before
+// they were recorded leave them out and were rated with elo.Default.
+type Document struct {
+	Format       string          `json:"format"`
+	Version      int             `json:"version"`
+	ExportedAt   time.Time       `json:"exported_at"`
+	RatingSystem string          `json:"rating_system,omitempty"`
+	KFactor      int             `json:"k_factor,omitempty"`
+	Tables       []models.Table  `json:"tables"`
+	Players      []models.Player `json:"players"`
+	Games        []models.Game   `json:"games"`
+	Events       []ledger.Event  `json:"events"`
+}
+
+// Calculator is the calculator the document's ratings were computed with.
+func (d *Document) Calculator() elo.Calculator {
+	if d.RatingSystem == "" {
+		return elo.Default
+	}
+	return elo.Calculator{System: d.RatingSystem, KFactor: float64(d.KFactor)}
+}
+
+// Sink receives rows as they are read so an export never has to hold the
+// whole database in memory. Rows arrive grouped: all tables, then all
+// players, then all games, then all events.
+type Sink interface {
+	Table(t models.Table) error
+	Player(p models.Player) error
+	Game(g models.Game) error
+	Event(e ledger.Event) error
+}
+
+var sections = []string{"tables", "players", "games", "events"}
+
+// StreamWriter is a Sink that writes a Document to w as rows arrive.
+type StreamWriter struct {
+	w      io.Writer
+	enc    *json.Encoder
+	opened int
+	first  bool
+}
+
+// NewStreamWriter starts a document for an organization rated with settings.
+func NewStreamWriter(w io.Writer, exportedAt time.Time, settings models.OrganizationSettings) (*StreamWriter, error) {
+	header, err := json.Marshal(struct {
+		Format       string    `json:"format"`
+		Version      int       `json:"version"`
+		ExportedAt   time.Time `json:"exported_at"`
+		RatingSystem string    `json:"rating_system"`
+		KFactor      int       `json:"k_factor"`
+	}{Format, FormatVersion, exportedAt, settings.RatingSystem, settings.KFactor})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reopen the header object so the row arrays can follow it.
+	if _, err := w.Write(header[:len(header)-1]); err != nil {
+		return nil, err
+	}
+	return &StreamWriter{w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (s *StreamWriter) Table(t models.Table) error   { return s.write("tables", t) }
+func (s *StreamWriter) Player(p models.Player) error { return s.write("players", p) }
+func (s *StreamWriter) Game(g models.Game) error     { return s.write("games", g) }
+func (s *StreamWriter) Event(e ledger.Event) error   { return s.write("events", e) }
+
+// Close finishes the document, emitting empty arrays for sections that had
+// no rows.
+func (s *StreamWriter) Close() error {
+	if err := s.open(sections[len(sections)-1]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, "]}\n")
+	return err
+}
+
+func (s *StreamWriter) write(section string, v interface{}) error {
+	if err := s.open(section); err != nil {
+		return err
+	}
+	if !s.first {
+		if _, err := io.WriteString(s.w, ","); err != nil {
+			return err
+		}
+	}
+	s.first = false
+	return s.enc.Encode(v)
+}
+
+// open moves the writer forward to section, closing any earlier sections.
+func (s *StreamWriter) open(section string) error {
+	for s.opened == 0 || sections[s.opened-1] != section {
+		if s.opened == len(sections) {
+			return fmt.Errorf("section %q written out of order", section)
+		}
+		prefix := "],"
+		if s.opened == 0 {
+			prefix = ","
+		}
+		if _, err := fmt.Fprintf(s.w, "%s%q:[", prefix, sections[s.opened]); err != nil {
+			return err
+		}
+		s.opened++
+		s.first = true
+	}
+	return nil
+}
+
+// Validate checks that the document is internally consistent before it is
+// written anywhere. Games from exports made before played_at existed are
+// taken to have been played when they were recorded.
+func (d *Document) Validate() error {
+	if d.Format != Format {
+		return fmt.Errorf("unsupported format %q", d.Format)
+	}
+	if d.Version != FormatVersion {
+		return fmt.Errorf("unsupported format version %d", d.Version)
+	}
+	if d.RatingSystem != "" && !slices.Contains(elo.Systems, d.RatingSystem) {
+		return fmt.Errorf("unknown rating system %q", d.RatingSystem)
+	}
+	if d.RatingSystem != "" && d.KFactor <= 0 {
+		return fmt.Errorf("k_factor must be positive")
+	}
+
+	for i := range d.Games {
+		if d.Games[i].PlayedAt.IsZero() {
+			d.Games[i].PlayedAt = d.Games[i].CreatedAt
+		}
+	}
+
+	tables := make(map[int]float64, len(d.Tables))
+	for _, t := range d.Tables {
+		if t.ID <= 0 {
+			return fmt.Errorf("table %d: invalid ID", t.ID)
+		}
+		if _, dup := tables[t.ID]; dup {
+			return fmt.Errorf("table %d: duplicate ID", t.ID)
+		}
+		if t.Name == "" {
+			return fmt.Errorf("table %d: name is required", t.ID)
+		}
+		if t.KMultiplier <= 0 {
+			return fmt.Errorf("table %d: k_multiplier must be positive", t.ID)
+		}
+		tables[t.ID] = t.KMultiplier
+	}
+
+	players := make(map[int]*models.Player, len(d.Players))
+	for i := range d.Players {
+		p := &d.Players[i]
+		if p.ID <= 0 {
+			return fmt.Errorf("player %d: invalid ID", p.ID)
+		}
+		if _, dup := players[p.ID]; dup {
+			return fmt.Errorf("player %d: duplicate ID", p.ID)
+		}
+		if p.Name == "" {
+			return fmt.Errorf("player %d: name is required", p.ID)
+		}
+		players[p.ID] = p
+	}
+
+	type appearance struct {
+		playedAt    time.Time
+		gameID      int
+		ratingAfter int
+	}
+	history := make(map[int][]appearance)
+	gameIDs := make(map[int]bool, len(d.Games))
+
+	for _, g := range d.Games {
+		if g.ID <= 0 {
+			return fmt.Errorf("game %d: invalid ID", g.ID)
+		}
+		if gameIDs[g.ID] {
+			return fmt.Errorf("game %d: duplicate ID", g.ID)
+		}
+		gameIDs[g.ID] = true
+		if g.GameType != "singles" && g.GameType != "doubles" {
+			return fmt.Errorf("game %d: invalid game type %q", g.ID, g.GameType)
+		}
+		if g.TableID != nil {
+			if _, ok := tables[*g.TableID]; !ok {
+				return fmt.Errorf("game %d: unknown table %d", g.ID, *g.TableID)
+			}
+		}
+
+		inGame := make(map[int]bool)
+		teamScores := make(map[int]int)
+		teamSides := make(map[int]string)
+		for _, gp := range g.Players {
+			if gp.Team != 1 && gp.Team != 2 {
+				return fmt.Errorf("game %d: invalid team %d", g.ID, gp.Team)
+			}
+			if _, ok := players[gp.PlayerID]; !ok {
+				return fmt.Errorf("game %d: unknown player %d", g.ID, gp.PlayerID)
+			}
+			if inGame[gp.PlayerID] {
+				return fmt.Errorf("game %d: player %d appears more than once", g.ID, gp.PlayerID)
+			}
+			inGame[gp.PlayerID] = true
+			if score, ok := teamScores[gp.Team]; ok && score != gp.Score {
+				return fmt.Errorf("game %d: team %d has inconsistent scores", g.ID, gp.Team)
+			}
+			teamScores[gp.Team] = gp.Score
+			if side, ok := teamSides[gp.Team]; ok && side != gp.Side {
+				return fmt.Errorf("game %d: team %d has inconsistent sides", g.ID, gp.Team)
+			}
+			teamSides[gp.Team] = gp.Side
+			history[gp.PlayerID] = append(history[gp.PlayerID], appearance{g.PlayedAt, g.ID, gp.RatingAfter})
+		}
+
+		if len(g.Goals) > 0 {
+			teams := []models.CreateGameTeam{{}, {}}
+			for _, gp := range g.Players {
+				teams[gp.Team-1].PlayerIDs = append(teams[gp.Team-1].PlayerIDs, gp.PlayerID)
+			}
+			if err := ledger.ScoreGoals(teams, append([]models.Goal(nil), g.Goals...)); err != nil {
+				return fmt.Errorf("game %d: %w", g.ID, err)
+			}
+			for i, team := range teams {
+				if score, ok := teamScores[i+1]; ok && score != team.Score {
+					return fmt.Errorf("game %d: team %d has a score of %d but %d goals", g.ID, i+1, score, team.Score)
+				}
+			}
+		}
+	}
+
+	for id, p := range players {
+		games := history[id]
+		if p.GamesPlayed != len(games) {
+			return fmt.Errorf("player %d: games_played is %d but %d games were found", id, p.GamesPlayed, len(games))
+		}
+
+		expected := elo.InitialRating
+		if len(games) > 0 {
+			sort.Slice(games, func(i, j int) bool {
+				if !games[i].playedAt.Equal(games[j].playedAt) {
+					return games[i].playedAt.Before(games[j].playedAt)
+				}
+				return games[i].gameID < games[j].gameID
+			})
+			expected = games[len(games)-1].ratingAfter
+		}
+		if p.Rating != expected {
+			return fmt.Errorf("player %d: rating is %d but the last game left it at %d", id, p.Rating, expected)
+		}
+	}
+
+	var lastEventID int64
+	for _, e := range d.Events {
+		if e.ID <= lastEventID {
+			return fmt.Errorf("event %d: IDs must be unique and increasing", e.ID)
+		}
+		lastEventID = e.ID
+	}
+	if _, err := ledger.Project(d.Events, d.Calculator(), tables); err != nil {
+		return fmt.Errorf("event ledger: %w", err)
+	}
+
+	return nil
+}
+
+// EnsureEvents fills in a ledger for documents that were written without
+// one, such as hand-built imports, so the imported data can still be rebuilt
+// from events. Players and games are recorded in the order they were created.
+func (d *Document) EnsureEvents() error {
+	if len(d.Events) > 0 {
+		return nil
+	}
+
+	type entry struct {
+		createdAt time.Time
+		kind      int
+		id        int
+		eventType string
+		payload   interface{}
+	}
+	var entries []entry
+
+	for _, p := range d.Players {
+		entries = append(entries, entry{p.CreatedAt, 0, p.ID, ledger.PlayerCreated,
+			ledger.PlayerCreatedPayload{PlayerID: p.ID, Name: p.Name, CreatedAt: p.CreatedAt}})
+	}
+	for _, g := range d.Games {
+		teams := []models.CreateGameTeam{{PlayerIDs: []int{}}, {PlayerIDs: []int{}}}
+		for _, gp := range g.Players {
+			team := &teams[gp.Team-1]
+			team.PlayerIDs = append(team.PlayerIDs, gp.PlayerID)
+			team.Score = gp.Score
+			team.Side = gp.Side
+		}
+		playedAt := g.PlayedAt
+		entries = append(entries, entry{g.CreatedAt, 1, g.ID, ledger.GameRecorded,
+			ledger.GameRecordedPayload{GameID: g.ID, GameType: g.GameType, Teams: teams, TableID: g.TableID, PlayedAt: &playedAt, Goals: g.Goals, CreatedAt: g.CreatedAt}})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.createdAt.Equal(b.createdAt) {
+			return a.createdAt.Before(b.createdAt)
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.id < b.id
+	})
+
+	for i, e := range entries {
+		payload, err := json.Marshal(e.payload)
+		if err != nil {
+			return err
+		}
+		d.Events = append(d.Events, ledger.Event{
+			ID:          int64(i + 1),
+			Type:        e.eventType,
+			AggregateID: e.id,
+			Payload:     payload,
+			Actor:       "import",
+			CreatedAt:   e.createdAt,
+		})
+	}
+	return nil
+}
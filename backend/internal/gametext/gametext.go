@@ -0,0 +1,266 @@
+// Package gametext turns game results written as free text, such as
+// "Alice & Bob 10 - 6 Carol & Dan" or "alice beat bob 10-3", into requests
+// to record them.
+package gametext
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// ErrInvalid is wrapped by errors describing text that is not a game result.
+var ErrInvalid = errors.New("invalid game result")
+
+// Example is a result in a form Parse understands, for help messages.
+const Example = "Alice & Bob 10-6 Carol & Dan"
+
+// A score is two numbers joined by a dash or colon, set apart from the
+// names around it.
+var scorePattern = regexp.MustCompile(`(?:^|[\s,])(\d+)\s*[-:–—]\s*(\d+)(?:$|[\s,.!])`)
+
+// How the first team did, as told by the words between the teams.
+const (
+	outcomeWon = iota + 1
+	outcomeLost
+	outcomeVersus
+)
+
+// connectives are the words that may sit between the teams, longest first so
+// "won against" is not read as "against".
+var connectives = []struct {
+	words   []string
+	outcome int
+}{
+	{[]string{"won", "against"}, outcomeWon},
+	{[]string{"won", "over"}, outcomeWon},
+	{[]string{"lost", "to"}, outcomeLost},
+	{[]string{"lost", "against"}, outcomeLost},
+	{[]string{"loses", "to"}, outcomeLost},
+	{[]string{"beat"}, outcomeWon},
+	{[]string{"beats"}, outcomeWon},
+	{[]string{"def"}, outcomeWon},
+	{[]string{"def."}, outcomeWon},
+	{[]string{"defeated"}, outcomeWon},
+	{[]string{"defeats"}, outcomeWon},
+	{[]string{"vs"}, outcomeVersus},
+	{[]string{"vs."}, outcomeVersus},
+	{[]string{"v"}, outcomeVersus},
+	{[]string{"versus"}, outcomeVersus},
+	{[]string{"against"}, outcomeVersus},
+}
+
+// joiners separate teammates.
+var joiners = []string{"&", "and", "+", ",", "/", "with"}
+
+// Result is a parsed game: the request that records it and the players
+// matched for each of its teams.
+type Result struct {
+	Request models.CreateGameRequest `json:"request"`
+	Teams   [2][]Player              `json:"teams"`
+}
+
+// Parse reads a game result written as two teams and a score. The score may
+// come between the teams ("Alice 10-6 Bob", scores in team order) or after
+// them, when the teams are joined by a word saying who won ("Alice beat Bob
+// 10-6", "Alice lost to Bob 6-10") or by "vs" (scores in team order).
+// Teammates are joined by "&", "and", "+", "," or "/", and players are
+// matched against players as described for Match. Names that match nobody,
+// or several players equally well, are reported together in a *MatchError;
+// other problems wrap ErrInvalid.
+func Parse(text string, players []Player) (*Result, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: expected a result such as %q", ErrInvalid, Example)
+	}
+
+	scores := scorePattern.FindAllStringSubmatchIndex(text, -1)
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("%w: a score such as 10-6 is required", ErrInvalid)
+	}
+	if len(scores) > 1 {
+		return nil, fmt.Errorf("%w: only one score is allowed", ErrInvalid)
+	}
+	m := scores[0]
+	first, err1 := strconv.Atoi(text[m[2]:m[3]])
+	second, err2 := strconv.Atoi(text[m[4]:m[5]])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%w: score is too large", ErrInvalid)
+	}
+	before := words(text[:m[2]])
+	after := words(strings.TrimRight(text[m[5]:], ".! "))
+
+	var team1, team2 []string
+	outcome := outcomeVersus
+	switch {
+	case len(before) == 0:
+		return nil, fmt.Errorf("%w: name the teams before the score", ErrInvalid)
+	case len(after) > 0:
+		if i, _, _ := findConnective(before); i >= 0 {
+			return nil, fmt.Errorf("%w: unexpected text after the score: %q", ErrInvalid, strings.Join(after, " "))
+		}
+		team1, team2 = before, after
+	default:
+		i, n, o := findConnective(before)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: put the score between the teams or say who beat whom, e.g. %q", ErrInvalid, Example)
+		}
+		team1, team2, outcome = before[:i], before[i+n:], o
+	}
+	if len(team1) == 0 || len(team2) == 0 {
+		return nil, fmt.Errorf("%w: both teams need at least one player", ErrInvalid)
+	}
+
+	switch outcome {
+	case outcomeWon:
+		first, second = max(first, second), min(first, second)
+	case outcomeLost:
+		first, second = min(first, second), max(first, second)
+	}
+	if first == second {
+		return nil, fmt.Errorf("%w: a game cannot end in a draw", ErrInvalid)
+	}
+
+	var result Result
+	var matchErr MatchError
+	for i, team := range [][]string{team1, team2} {
+		for _, name := range teamNames(team) {
+			player, err := matchName(name, players)
+			var nameErr *MatchError
+			if errors.As(err, &nameErr) {
+				matchErr.Unknown = append(matchErr.Unknown, nameErr.Unknown...)
+				matchErr.Ambiguous = append(matchErr.Ambiguous, nameErr.Ambiguous...)
+				continue
+			}
+			result.Teams[i] = append(result.Teams[i], player...)
+		}
+	}
+	if len(matchErr.Unknown) > 0 || len(matchErr.Ambiguous) > 0 {
+		return nil, &matchErr
+	}
+
+	size := len(result.Teams[0])
+	if size != len(result.Teams[1]) || size < 1 || size > 2 {
+		return nil, fmt.Errorf("%w: teams must both have one player or both have two, not %d and %d",
+			ErrInvalid, size, len(result.Teams[1]))
+	}
+
+	result.Request.GameType = "singles"
+	if size == 2 {
+		result.Request.GameType = "doubles"
+	}
+	result.Request.Teams = make([]models.CreateGameTeam, 2)
+	result.Request.Teams[0].Score, result.Request.Teams[1].Score = first, second
+	seen := make(map[int]bool)
+	for i, team := range result.Teams {
+		for _, p := range team {
+			if seen[p.ID] {
+				return nil, fmt.Errorf("%w: %s is named more than once", ErrInvalid, p.Name)
+			}
+			seen[p.ID] = true
+			result.Request.Teams[i].PlayerIDs = append(result.Request.Teams[i].PlayerIDs, p.ID)
+		}
+	}
+	return &result, nil
+}
+
+// words splits text into words, setting joiners written against names, as
+// in "Alice&Bob", apart.
+func words(text string) []string {
+	for _, j := range []string{"&", "+", ",", "/"} {
+		text = strings.ReplaceAll(text, j, " "+j+" ")
+	}
+	return strings.Fields(text)
+}
+
+// findConnective returns the position and length of the first word or
+// phrase in ws that separates two teams, and the outcome it tells, or -1 if
+// there is none.
+func findConnective(ws []string) (int, int, int) {
+	for i := range ws {
+		for _, c := range connectives {
+			if i+len(c.words) > len(ws) {
+				continue
+			}
+			matched := true
+			for j, w := range c.words {
+				if !strings.EqualFold(ws[i+j], w) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return i, len(c.words), c.outcome
+			}
+		}
+	}
+	return -1, 0, 0
+}
+
+// teamNames splits a team's words into the names of its players. Mentions
+// always stand alone; other words run together into one name until a
+// joiner, so names may have spaces in them.
+func teamNames(ws []string) [][]string {
+	var names [][]string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			names = append(names, current)
+			current = nil
+		}
+	}
+	for _, w := range ws {
+		switch {
+		case slices.ContainsFunc(joiners, func(j string) bool { return strings.EqualFold(j, w) }):
+			flush()
+		case isMention(w):
+			flush()
+			names = append(names, []string{w})
+		default:
+			current = append(current, w)
+		}
+	}
+	flush()
+	return names
+}
+
+// matchName matches the words of one name. When they do not match anyone
+// together, each word is tried as a name of its own, so teammates may also
+// be separated by spaces alone ("alice bob beat carol dan 10-2").
+func matchName(name []string, players []Player) ([]Player, error) {
+	p, err := Match(strings.Join(name, " "), players)
+	if err == nil {
+		return []Player{*p}, nil
+	}
+	var matchErr *MatchError
+	if len(name) == 1 || !errors.As(err, &matchErr) || len(matchErr.Unknown) == 0 {
+		return nil, err
+	}
+
+	// Once some of the words match players on their own, what is wrong
+	// with the others says more than the name as a whole not matching.
+	matched := make([]Player, 0, len(name))
+	var wordsErr MatchError
+	for _, w := range name {
+		p, wordErr := Match(w, players)
+		if wordErr != nil {
+			errors.As(wordErr, &matchErr)
+			wordsErr.Unknown = append(wordsErr.Unknown, matchErr.Unknown...)
+			wordsErr.Ambiguous = append(wordsErr.Ambiguous, matchErr.Ambiguous...)
+			continue
+		}
+		matched = append(matched, *p)
+	}
+	if len(matched) == 0 {
+		return nil, err
+	}
+	if len(matched) < len(name) {
+		return nil, &wordsErr
+	}
+	return matched, nil
+}
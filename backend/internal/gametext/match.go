@@ -0,0 +1,194 @@
+package gametext
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// minSimilarity is how alike a misspelled name has to be to a player's name,
+// as the share of characters that need no edit, to match it.
+const minSimilarity = 0.75
+
+// How well a name matches a player, from best to worst.
+const (
+	matchNone = iota
+	matchFuzzy
+	matchPrefix
+	matchExact
+)
+
+// Player is someone names can be matched to. Handles are the chat handles
+// linked to them, which only match exactly.
+type Player struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Handles []string `json:"-"`
+}
+
+// Ambiguity is a name that matched several players equally well.
+type Ambiguity struct {
+	Name       string   `json:"name"`
+	Candidates []Player `json:"candidates"`
+}
+
+// MatchError reports the names that could not be matched to one player.
+type MatchError struct {
+	Unknown   []string    `json:"unknown,omitempty"`
+	Ambiguous []Ambiguity `json:"ambiguous,omitempty"`
+}
+
+func (e *MatchError) Error() string {
+	var problems []string
+	for _, name := range e.Unknown {
+		problems = append(problems, fmt.Sprintf("no player matches %q", name))
+	}
+	for _, a := range e.Ambiguous {
+		names := make([]string, len(a.Candidates))
+		for i, c := range a.Candidates {
+			names[i] = c.Name
+		}
+		problems = append(problems, fmt.Sprintf("%q could be %s", a.Name, orList(names)))
+	}
+	return strings.Join(problems, "; ")
+}
+
+func orList(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// Match finds the player a name refers to. A chat mention, either @handle or
+// Slack's escaped <@U123|handle>, matches the player a handle is linked to,
+// falling back to their name. Names match, in order of preference, a
+// player's name exactly, ignoring case; the start of their name or of a word
+// in it; or their name misspelled by a letter or two. Only the best kind of
+// match counts, and if several players match that well (or, for misspellings,
+// equally closely) the name is ambiguous. Either failure is a *MatchError.
+func Match(name string, players []Player) (*Player, error) {
+	display := name
+	candidates := []string{name}
+	if isMention(name) {
+		candidates = mentionHandles(name)
+		display = "@" + candidates[len(candidates)-1]
+		for _, handle := range candidates {
+			for i := range players {
+				for _, h := range players[i].Handles {
+					if strings.EqualFold(strings.TrimPrefix(h, "@"), handle) {
+						return &players[i], nil
+					}
+				}
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if best := bestMatches(strings.ToLower(candidate), players); len(best) == 1 {
+			return &best[0], nil
+		} else if len(best) > 1 {
+			return nil, &MatchError{Ambiguous: []Ambiguity{{Name: display, Candidates: best}}}
+		}
+	}
+	return nil, &MatchError{Unknown: []string{display}}
+}
+
+// bestMatches returns the players that match name best.
+func bestMatches(name string, players []Player) []Player {
+	var best []Player
+	bestKind, bestSimilarity := matchNone, 0.0
+	for _, p := range players {
+		kind, similarity := matchPlayer(name, p)
+		if kind == matchNone {
+			continue
+		}
+		if kind > bestKind || (kind == bestKind && similarity > bestSimilarity) {
+			best, bestKind, bestSimilarity = nil, kind, similarity
+		}
+		if kind == bestKind && similarity == bestSimilarity {
+			best = append(best, p)
+		}
+	}
+	return best
+}
+
+func matchPlayer(name string, p Player) (int, float64) {
+	full := strings.ToLower(p.Name)
+	if name == full {
+		return matchExact, 1
+	}
+	for _, h := range p.Handles {
+		if strings.EqualFold(strings.TrimPrefix(h, "@"), name) {
+			return matchExact, 1
+		}
+	}
+
+	if utf8.RuneCountInString(name) >= 2 {
+		if strings.HasPrefix(full, name) {
+			return matchPrefix, 1
+		}
+		for _, word := range strings.Fields(full)[1:] {
+			if strings.HasPrefix(word, name) {
+				return matchPrefix, 1
+			}
+		}
+	}
+
+	similarity := 0.0
+	for _, target := range append([]string{full}, strings.Fields(full)...) {
+		a, b := []rune(name), []rune(target)
+		longest := max(len(a), len(b))
+		if s := 1 - float64(editDistance(a, b))/float64(longest); s > similarity {
+			similarity = s
+		}
+	}
+	if similarity >= minSimilarity {
+		return matchFuzzy, similarity
+	}
+	return matchNone, 0
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// neighbouring letters it takes to turn a into b.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func isMention(word string) bool {
+	return (strings.HasPrefix(word, "<@") && strings.HasSuffix(word, ">")) ||
+		(strings.HasPrefix(word, "@") && len(word) > 1)
+}
+
+// mentionHandles returns the handles a mention may refer to: for Slack's
+// <@U123|name> both the user ID and the name.
+func mentionHandles(mention string) []string {
+	if strings.HasPrefix(mention, "<@") {
+		id, name, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(mention, "<@"), ">"), "|")
+		if name == "" {
+			return []string{id}
+		}
+		return []string{id, name}
+	}
+	return []string{strings.TrimPrefix(mention, "@")}
+}
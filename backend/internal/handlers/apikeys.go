@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const maxAPIKeyNameLength = 255
+
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.repo.ListAPIKeys(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch API keys", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, keys)
+}
+
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if len(req.Name) > maxAPIKeyNameLength {
+		respondError(w, http.StatusBadRequest, "Name must be at most 255 characters")
+		return
+	}
+	if !slices.Contains(repository.Roles, req.Role) {
+		respondError(w, http.StatusBadRequest, "Role must be 'viewer', 'recorder' or 'admin'")
+		return
+	}
+
+	key, err := h.repo.CreateAPIKey(r.Context(), req)
+	if err != nil {
+		respondServerError(w, r, "Failed to create API key", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, key)
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	key, err := h.repo.RevokeAPIKey(r.Context(), keyID)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to revoke API key", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, key)
+}
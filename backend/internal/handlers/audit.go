@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+func (h *Handler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		EntityType: query.Get("entity_type"),
+		Actor:      query.Get("actor"),
+		Limit:      defaultAuditLimit,
+	}
+
+	switch filter.EntityType {
+	case "", repository.AuditEntityPlayer, repository.AuditEntityGame, repository.AuditEntityOrganization, repository.AuditEntityTable, repository.AuditEntityWebhook, repository.AuditEntityAPIKey, repository.AuditEntityUser:
+	default:
+		respondError(w, http.StatusBadRequest, "Entity type must be 'player', 'game', 'organization', 'table', 'webhook', 'api_key' or 'user'")
+		return
+	}
+
+	if v := query.Get("entity_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid entity ID")
+			return
+		}
+		filter.EntityID = &id
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid 'from' time")
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid 'to' time")
+		return
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			respondError(w, http.StatusBadRequest, "Limit must be between 1 and 1000")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.repo.ListAuditLog(r.Context(), filter)
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch audit log", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// parseTimeParam accepts either an RFC 3339 timestamp or a plain date. An
+// empty value yields a nil time.
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/logging"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const (
+	// sessionCookie holds the session token of a signed-in user.
+	sessionCookie = "foos_session"
+	// operatorActor is who changes made with the operator key are
+	// attributed to.
+	operatorActor = "operator"
+)
+
+// caller is who is making a request, when they identified themselves with
+// an API key or a session cookie. Exactly one of key and user is set, unless
+// the caller is the operator, who belongs to no organization and has no role
+// in any.
+type caller struct {
+	organizationID int
+	role           string
+	key            *models.APIKey
+	user           *models.User
+	operator       bool
+}
+
+type callerKey struct{}
+
+func callerFromContext(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerKey{}).(*caller)
+	return c
+}
+
+// Authenticate identifies callers presenting an API key, as a bearer token
+// or in the X-API-Key header, or a session cookie from signing in, and
+// attributes their changes to the key or user. Requests with neither carry
+// on anonymously. A key that is unknown or revoked is refused, while an
+// expired session is cleared and the request carries on anonymously, so
+// signed-out browsers can still read.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-API-Key")
+		if auth := r.Header.Get("Authorization"); token == "" && auth != "" {
+			scheme, credentials, _ := strings.Cut(auth, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				respondError(w, http.StatusUnauthorized, "Authorization must be a Bearer token")
+				return
+			}
+			token = strings.TrimSpace(credentials)
+		}
+		if token != "" && h.config.OperatorKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.config.OperatorKey)) == 1 {
+			ctx := context.WithValue(r.Context(), callerKey{}, &caller{operator: true})
+			ctx = repository.WithActor(ctx, operatorActor)
+			ctx = logging.With(ctx, "actor", operatorActor)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		if token != "" {
+			key, err := h.repo.AuthenticateAPIKey(r.Context(), token)
+			if errors.Is(err, repository.ErrInvalidAPIKey) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				respondError(w, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+			if err != nil {
+				respondServerError(w, r, "Failed to check API key", err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), callerKey{}, &caller{organizationID: key.OrganizationID, role: key.Role, key: key})
+			ctx = repository.WithActor(ctx, "api-key:"+key.Name)
+			ctx = logging.With(ctx, "actor", "api-key:"+key.Name)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil || cookie.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := h.repo.SessionUser(r.Context(), cookie.Value)
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			h.clearSessionCookie(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			respondServerError(w, r, "Failed to check session", err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), callerKey{}, &caller{organizationID: user.OrganizationID, role: user.Role, user: user})
+		ctx = repository.WithActor(ctx, "user:"+user.Username)
+		ctx = repository.WithUser(ctx, user.ID)
+		ctx = logging.With(ctx, "actor", "user:"+user.Username)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (h *Handler) setSessionCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(h.config.SessionTTL),
+		HttpOnly: true,
+		Secure:   h.config.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *Handler) clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.config.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// RequireRole only lets through callers with at least role: keys and users
+// of the organization being used that have the role, or anonymous callers
+// when that organization's anonymous role is enough.
+func (h *Handler) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := callerFromContext(r.Context())
+			if c == nil {
+				if !repository.HasRole(anonymousRole(r), role) {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					respondError(w, http.StatusUnauthorized, "Sign in or use an API key")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if c.operator {
+				respondError(w, http.StatusForbidden, "The operator key only manages organizations and reads metrics")
+				return
+			}
+			if !c.inOrganization(r.Context()) {
+				if c.key != nil {
+					respondError(w, http.StatusForbidden, "API key belongs to another organization")
+				} else {
+					respondError(w, http.StatusForbidden, "You are signed in to another organization")
+				}
+				return
+			}
+			if !repository.HasRole(c.role, role) {
+				respondError(w, http.StatusForbidden, "This requires the '"+role+"' role")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireOperator only lets through the deployment's operator, for the
+// endpoints that span organizations.
+func (h *Handler) RequireOperator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := callerFromContext(r.Context())
+		if c == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondError(w, http.StatusUnauthorized, "This needs the operator key")
+			return
+		}
+		if !c.operator {
+			respondError(w, http.StatusForbidden, "This needs the operator key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePlayerOrRole lets signed-in users through to routes about the
+// player they are linked to, given by the {id} URL parameter, and otherwise
+// works like RequireRole.
+func (h *Handler) RequirePlayerOrRole(role string) func(http.Handler) http.Handler {
+	requireRole := h.RequireRole(role)
+	return func(next http.Handler) http.Handler {
+		withRole := requireRole(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := callerFromContext(r.Context())
+			if c != nil && c.user != nil && c.user.PlayerID != nil && c.inOrganization(r.Context()) {
+				if playerID, err := strconv.Atoi(chi.URLParam(r, "id")); err == nil && playerID == *c.user.PlayerID {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			withRole.ServeHTTP(w, r)
+		})
+	}
+}
+
+// callerPlaysFor reports whether the caller is signed in as one of players.
+func callerPlaysFor(r *http.Request, players []int) bool {
+	c := callerFromContext(r.Context())
+	return c != nil && c.user != nil && c.user.PlayerID != nil && slices.Contains(players, *c.user.PlayerID)
+}
+
+// requirePlayerOrAdmin checks the caller is signed in as one of players or
+// is an admin, and otherwise responds with forbidden, or asks anonymous
+// callers to sign in.
+func (h *Handler) requirePlayerOrAdmin(w http.ResponseWriter, r *http.Request, players []int, forbidden string) bool {
+	c := callerFromContext(r.Context())
+	role := anonymousRole(r)
+	if c != nil {
+		role = c.role
+	}
+	if repository.HasRole(role, repository.RoleAdmin) || callerPlaysFor(r, players) {
+		return true
+	}
+	if c == nil {
+		respondError(w, http.StatusUnauthorized, "Sign in to do that")
+	} else {
+		respondError(w, http.StatusForbidden, forbidden)
+	}
+	return false
+}
+
+// anonymousRole is the role of anonymous callers in the organization the
+// request is scoped to. Requests scoped to none get no role.
+func anonymousRole(r *http.Request) string {
+	org, ok := repository.OrganizationFromContext(r.Context())
+	if !ok {
+		return repository.RoleNone
+	}
+	return org.AnonymousRole
+}
+
+// inOrganization reports whether the caller belongs to the organization
+// the request is scoped to, if it is scoped to one.
+func (c *caller) inOrganization(ctx context.Context) bool {
+	org, ok := repository.OrganizationFromContext(ctx)
+	return !ok || org.ID == c.organizationID
+}
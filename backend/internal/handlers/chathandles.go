@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const maxChatHandleLength = 255
+
+func (h *Handler) ListChatHandles(w http.ResponseWriter, r *http.Request) {
+	handles, err := h.repo.ListChatHandles(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch chat handles", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, handles)
+}
+
+func (h *Handler) SetChatHandle(w http.ResponseWriter, r *http.Request) {
+	var req models.ChatHandleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	handle := repository.NormalizeChatHandle(req.Handle)
+	if handle == "" {
+		respondError(w, http.StatusBadRequest, "Handle is required")
+		return
+	}
+	if len(handle) > maxChatHandleLength {
+		respondError(w, http.StatusBadRequest, "Handle must be at most 255 characters")
+		return
+	}
+
+	linked, err := h.repo.SetChatHandle(r.Context(), handle, req.PlayerID)
+	if errors.Is(err, repository.ErrChatHandleNotFound) {
+		respondError(w, http.StatusBadRequest, "Player not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to link chat handle", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, linked)
+}
+
+func (h *Handler) DeleteChatHandle(w http.ResponseWriter, r *http.Request) {
+	err := h.repo.DeleteChatHandle(r.Context(), chi.URLParam(r, "handle"))
+	if errors.Is(err, repository.ErrChatHandleNotFound) {
+		respondError(w, http.StatusNotFound, "Chat handle not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to unlink chat handle", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Chat handle unlinked"})
+}
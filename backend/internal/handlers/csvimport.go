@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/csvimport"
+)
+
+func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	rows, rowErrors, err := csvimport.Parse(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := csvimport.NewReport(rows, rowErrors, dryRun)
+	if err := h.repo.ImportHistoricalGames(r.Context(), rows, dryRun, report); err != nil {
+		logError(r, "Failed to import games", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	report.SortErrors()
+
+	switch {
+	case len(report.Errors) > 0:
+		respondJSON(w, http.StatusUnprocessableEntity, report)
+	case dryRun:
+		respondJSON(w, http.StatusOK, report)
+	default:
+		respondJSON(w, http.StatusCreated, report)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const maxDisputeCommentLength = 2000
+
+var (
+	disputeStatuses    = []string{repository.DisputeOpen, repository.DisputeUpheld, repository.DisputeCorrected, repository.DisputeVoided}
+	disputeResolutions = disputeStatuses[1:]
+)
+
+// OpenDispute lets a player in a game, or an admin, challenge its result.
+func (h *Handler) OpenDispute(w http.ResponseWriter, r *http.Request) {
+	gameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	var req models.DisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Comment == "" {
+		respondError(w, http.StatusBadRequest, "Comment is required")
+		return
+	}
+	if len(req.Comment) > maxDisputeCommentLength {
+		respondError(w, http.StatusBadRequest, "Comment must be at most 2000 characters")
+		return
+	}
+
+	game, err := h.repo.GetGameByID(r.Context(), gameID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	players := make([]int, len(game.Players))
+	for i, gp := range game.Players {
+		players[i] = gp.PlayerID
+	}
+	if !h.requirePlayerOrAdmin(w, r, players, "Only a player in this game can dispute it") {
+		return
+	}
+
+	dispute, err := h.repo.OpenDispute(r.Context(), gameID, req.Comment)
+	if errors.Is(err, repository.ErrDisputeOpen) {
+		respondError(w, http.StatusConflict, "Game already has an open dispute")
+		return
+	}
+	if errors.Is(err, repository.ErrDisputeNotFound) {
+		respondError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to open dispute", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, dispute)
+}
+
+func (h *Handler) ListGameDisputes(w http.ResponseWriter, r *http.Request) {
+	gameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	disputes, err := h.repo.ListDisputes(r.Context(), &gameID, "", maxGamesLimit)
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch disputes", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, disputes)
+}
+
+func (h *Handler) ListDisputes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && !slices.Contains(disputeStatuses, status) {
+		respondError(w, http.StatusBadRequest, "Status must be 'open', 'upheld', 'corrected' or 'voided'")
+		return
+	}
+
+	limit := defaultGamesLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxGamesLimit {
+			respondError(w, http.StatusBadRequest, "Limit must be between 1 and 200")
+			return
+		}
+	}
+
+	disputes, err := h.repo.ListDisputes(r.Context(), nil, status, limit)
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch disputes", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, disputes)
+}
+
+func (h *Handler) ResolveDispute(w http.ResponseWriter, r *http.Request) {
+	disputeID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid dispute ID")
+		return
+	}
+
+	var req models.ResolveDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !slices.Contains(disputeResolutions, req.Resolution) {
+		respondError(w, http.StatusBadRequest, "Resolution must be 'upheld', 'corrected' or 'voided'")
+		return
+	}
+	scored := req.Team1Score != nil && req.Team2Score != nil
+	if req.Resolution == repository.DisputeCorrected && !scored && len(req.Goals) == 0 {
+		respondError(w, http.StatusBadRequest, "A correction needs team1_score and team2_score, or goals")
+		return
+	}
+	if req.Resolution != repository.DisputeCorrected && (req.Team1Score != nil || req.Team2Score != nil || req.Goals != nil) {
+		respondError(w, http.StatusBadRequest, "Only a correction takes a score or goals")
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if len(req.Comment) > maxDisputeCommentLength {
+		respondError(w, http.StatusBadRequest, "Comment must be at most 2000 characters")
+		return
+	}
+
+	dispute, err := h.repo.ResolveDispute(r.Context(), disputeID, req)
+	if errors.Is(err, repository.ErrDisputeNotFound) {
+		respondError(w, http.StatusNotFound, "Dispute not found")
+		return
+	}
+	if errors.Is(err, repository.ErrDisputeResolved) {
+		respondError(w, http.StatusConflict, "Dispute is already resolved")
+		return
+	}
+	if errors.Is(err, ledger.ErrInvalidGoals) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logError(r, "Failed to resolve dispute", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, dispute)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidIfMatch = errors.New("invalid If-Match header")
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// parseIfMatch returns the version the client expects to be modifying, or nil
+// when the request is unconditional ("*" or no If-Match header).
+func parseIfMatch(r *http.Request) (*int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+	return &version, nil
+}
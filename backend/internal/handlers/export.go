@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/export"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="foosball-export-`+now.Format("20060102-150405")+`.json"`)
+
+	org, _ := repository.OrganizationFromContext(r.Context())
+	stream, err := export.NewStreamWriter(w, now, org.OrganizationSettings)
+	if err != nil {
+		logError(r, "Export failed", err)
+		return
+	}
+
+	// Once streaming has started the status line is gone, so a failure can
+	// only be logged; the client receives a truncated, invalid document.
+	if err := h.repo.Export(r.Context(), stream); err != nil {
+		logError(r, "Export failed", err)
+		return
+	}
+	if err := stream.Close(); err != nil {
+		logError(r, "Export failed", err)
+	}
+}
+
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	var doc export.Document
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := doc.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := doc.EnsureEvents(); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := h.repo.Import(r.Context(), &doc)
+	if errors.Is(err, repository.ErrOrganizationNotEmpty) {
+		respondError(w, http.StatusConflict, "Import requires an empty organization")
+		return
+	}
+	if errors.Is(err, repository.ErrImportIDConflict) {
+		respondError(w, http.StatusConflict, "Player or game IDs in the import are already in use")
+		return
+	}
+	if errors.Is(err, repository.ErrImportRatingSettings) {
+		respondError(w, http.StatusConflict, "The export was rated with a different rating system or K factor than this organization uses")
+		return
+	}
+	if errors.Is(err, repository.ErrImportReplayMismatch) {
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		logError(r, "Failed to import organization", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]int{
+		"tables":  len(doc.Tables),
+		"players": len(doc.Players),
+		"games":   len(doc.Games),
+		"events":  len(doc.Events),
+	})
+}
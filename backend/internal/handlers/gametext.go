@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/gametext"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// ParseGame reads a game result written as free text and returns the request
+// that would record it, with the players it was matched to, without
+// recording anything.
+func (h *Handler) ParseGame(w http.ResponseWriter, r *http.Request) {
+	var req models.ParseGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	players, err := h.gameTextPlayers(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch players", err)
+		return
+	}
+
+	result, err := gametext.Parse(req.Text, players)
+	var matchErr *gametext.MatchError
+	if errors.As(err, &matchErr) {
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":     matchErr.Error(),
+			"unknown":   matchErr.Unknown,
+			"ambiguous": matchErr.Ambiguous,
+		})
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := validateGameSetup(r, result.Request.GameType, result.Request.Teams); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// gameTextPlayers lists the organization's players, with their chat handles,
+// for names in free text to be matched against.
+func (h *Handler) gameTextPlayers(ctx context.Context) ([]gametext.Player, error) {
+	entries, err := h.repo.ListPlayers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	handles, err := h.repo.ListChatHandles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	players := make([]gametext.Player, len(entries))
+	index := make(map[int]int, len(entries))
+	for i, e := range entries {
+		players[i] = gametext.Player{ID: e.ID, Name: e.Name}
+		index[e.ID] = i
+	}
+	for _, handle := range handles {
+		if i, ok := index[handle.PlayerID]; ok {
+			players[i].Handles = append(players[i].Handles, handle.Handle)
+		}
+	}
+	return players, nil
+}
@@ -2,20 +2,52 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/logging"
 	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
 	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/stream"
 )
 
+const (
+	defaultGamesLimit = 50
+	maxGamesLimit     = 200
+	maxSideLength     = 20
+)
+
+// Config holds the settings handlers need beyond the repository.
+type Config struct {
+	// SlackSigningSecret verifies requests to the slash command endpoint,
+	// which is disabled when it is empty.
+	SlackSigningSecret string
+	// SessionTTL is how long users stay signed in.
+	SessionTTL time.Duration
+	// SecureCookies marks session cookies Secure, so browsers only send
+	// them over HTTPS.
+	SecureCookies bool
+	// OperatorKey is the key of whoever runs the deployment, the only
+	// caller who may list and create organizations. Those endpoints are
+	// closed when it is empty.
+	OperatorKey string
+}
+
 type Handler struct {
-	repo *repository.Repository
+	repo   *repository.Repository
+	live   *stream.Broker
+	config Config
 }
 
-func New(repo *repository.Repository) *Handler {
-	return &Handler{repo: repo}
+func New(repo *repository.Repository, config Config) *Handler {
+	return &Handler{repo: repo, live: stream.NewBroker(), config: config}
 }
 
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +68,7 @@ func (h *Handler) CreatePlayer(w http.ResponseWriter, r *http.Request) {
 
 	player, err := h.repo.CreatePlayer(r.Context(), req.Name)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to create player")
+		respondServerError(w, r, "Failed to create player", err)
 		return
 	}
 
@@ -46,7 +78,7 @@ func (h *Handler) CreatePlayer(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) ListPlayers(w http.ResponseWriter, r *http.Request) {
 	players, err := h.repo.ListPlayers(r.Context())
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch players")
+		respondServerError(w, r, "Failed to fetch players", err)
 		return
 	}
 	respondJSON(w, http.StatusOK, players)
@@ -59,32 +91,169 @@ func (h *Handler) CreateGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.GameType != "singles" && req.GameType != "doubles" {
-		respondError(w, http.StatusBadRequest, "Game type must be 'singles' or 'doubles'")
+	if msg := validateGameRequest(r, &req); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	r = withGamePlayers(r, req.Teams)
+
+	if h.needsConfirmation(r, req.Teams) {
+		pending, err := h.repo.SubmitGame(r.Context(), req)
+		if err != nil {
+			logError(r, "Failed to submit game", err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusAccepted, pending)
 		return
 	}
 
 	game, err := h.repo.CreateGame(r.Context(), req)
 	if err != nil {
+		logError(r, "Failed to record game", err)
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	respondJSON(w, http.StatusCreated, game)
 }
 
+// withGamePlayers makes the request's log records name the players of the
+// game it records.
+func withGamePlayers(r *http.Request, teams []models.CreateGameTeam) *http.Request {
+	var playerIDs []int
+	for _, team := range teams {
+		playerIDs = append(playerIDs, team.PlayerIDs...)
+	}
+	return r.WithContext(logging.With(r.Context(), "player_ids", playerIDs))
+}
+
+// validateGameRequest checks a game about to be recorded, filling in goal
+// teams and normalizing played_at, and returns a message describing the
+// first problem found.
+func validateGameRequest(r *http.Request, req *models.CreateGameRequest) string {
+	if msg := validateGameSetup(r, req.GameType, req.Teams); msg != "" {
+		return msg
+	}
+
+	if len(req.Goals) > 0 {
+		if err := ledger.ScoreGoals(req.Teams, req.Goals); err != nil {
+			return err.Error()
+		}
+	}
+
+	if req.PlayedAt != nil {
+		if req.PlayedAt.After(time.Now()) {
+			return "played_at cannot be in the future"
+		}
+		// Timestamps are stored without a zone, in UTC.
+		playedAt := req.PlayedAt.UTC()
+		req.PlayedAt = &playedAt
+	}
+	return ""
+}
+
 func (h *Handler) ListGames(w http.ResponseWriter, r *http.Request) {
-	games, err := h.repo.ListGames(r.Context(), 50)
+	query := r.URL.Query()
+	filter := models.GameFilter{
+		Cursor:   query.Get("cursor"),
+		Limit:    defaultGamesLimit,
+		GameType: query.Get("game_type"),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxGamesLimit {
+			respondError(w, http.StatusBadRequest, "Limit must be between 1 and 200")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if filter.GameType != "" && filter.GameType != "singles" && filter.GameType != "doubles" {
+		respondError(w, http.StatusBadRequest, "Game type must be 'singles' or 'doubles'")
+		return
+	}
+
+	var err error
+	if filter.PlayerID, err = parseIntParam(query.Get("player_id")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid player ID")
+		return
+	}
+	if filter.TeammateID, err = parseIntParam(query.Get("teammate_id")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid teammate ID")
+		return
+	}
+	if filter.OpponentID, err = parseIntParam(query.Get("opponent_id")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid opponent ID")
+		return
+	}
+	if filter.TableID, err = parseIntParam(query.Get("table_id")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid table ID")
+		return
+	}
+	if filter.PlayerID == nil && (filter.TeammateID != nil || filter.OpponentID != nil) {
+		respondError(w, http.StatusBadRequest, "Teammate and opponent filters require player_id")
+		return
+	}
+
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid 'from' time")
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid 'to' time")
+		return
+	}
+
+	if v := query.Get("disputed"); v != "" {
+		disputed, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Disputed must be 'true' or 'false'")
+			return
+		}
+		filter.Disputed = disputed
+	}
+
+	if v := query.Get("score"); v != "" {
+		high, low, ok := strings.Cut(v, "-")
+		a, errA := strconv.Atoi(high)
+		b, errB := strconv.Atoi(low)
+		if !ok || errA != nil || errB != nil {
+			respondError(w, http.StatusBadRequest, "Score must look like '10-7'")
+			return
+		}
+		filter.Score = &[2]int{a, b}
+	}
+
+	page, err := h.repo.ListGames(r.Context(), filter)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		respondError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch games")
+		respondServerError(w, r, "Failed to fetch games", err)
 		return
 	}
-	respondJSON(w, http.StatusOK, games)
+	respondJSON(w, http.StatusOK, page)
 }
 
 func (h *Handler) Leaderboard(w http.ResponseWriter, r *http.Request) {
-	entries, err := h.repo.GetLeaderboard(r.Context())
+	var entries []models.LeaderboardEntry
+	var err error
+
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		asOf, parseErr := parseAsOf(v)
+		if parseErr != nil {
+			respondError(w, http.StatusBadRequest, "Invalid 'as_of' time")
+			return
+		}
+		entries, err = h.repo.GetLeaderboardAsOf(r.Context(), asOf)
+	} else {
+		entries, err = h.repo.GetLeaderboard(r.Context())
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch leaderboard")
+		respondServerError(w, r, "Failed to fetch leaderboard", err)
 		return
 	}
 	respondJSON(w, http.StatusOK, entries)
@@ -100,6 +269,43 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	respondJSON(w, status, map[string]string{"error": message})
 }
 
+// respondServerError logs err and responds with a 500 and message, so the
+// caller is not shown what went wrong inside.
+func respondServerError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	logError(r, message, err)
+	respondError(w, http.StatusInternalServerError, message)
+}
+
+// logError logs err as the reason a request failed. The request's ID, route
+// and the IDs in its URL are added by the logger.
+func logError(r *http.Request, message string, err error, args ...any) {
+	slog.ErrorContext(r.Context(), message, append(args, "error", err)...)
+}
+
+// parseAsOf treats a plain date as the end of that day, so as_of=2026-06-30
+// includes every game played on the 30th.
+func parseAsOf(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, 1), nil
+}
+
+func parseIntParam(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
 func (h *Handler) DeleteGame(w http.ResponseWriter, r *http.Request) {
 	gameID := chi.URLParam(r, "id")
 	if gameID == "" {
@@ -107,8 +313,19 @@ func (h *Handler) DeleteGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.repo.DeleteGame(r.Context(), gameID)
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	err = h.repo.DeleteGame(r.Context(), gameID, expectedVersion)
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		respondError(w, http.StatusPreconditionFailed, "Game was modified by someone else")
+		return
+	}
 	if err != nil {
+		logError(r, "Failed to delete game", err)
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -116,6 +333,35 @@ func (h *Handler) DeleteGame(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Game deleted and ratings reverted"})
 }
 
+// validateGameSetup checks a game's type against the organization's formats
+// and normalises the teams' sides. It returns a message describing the first
+// problem, or "" if there is none.
+func validateGameSetup(r *http.Request, gameType string, teams []models.CreateGameTeam) string {
+	if gameType != "singles" && gameType != "doubles" {
+		return "Game type must be 'singles' or 'doubles'"
+	}
+	if org, ok := repository.OrganizationFromContext(r.Context()); ok && !slices.Contains(org.GameFormats, gameType) {
+		return "Game type '" + gameType + "' is not enabled for this organization"
+	}
+
+	if len(teams) == 2 {
+		for i := range teams {
+			teams[i].Side = strings.ToLower(strings.TrimSpace(teams[i].Side))
+		}
+		side1, side2 := teams[0].Side, teams[1].Side
+		if (side1 == "") != (side2 == "") {
+			return "Either both teams or neither must have a side"
+		}
+		if side1 != "" && side1 == side2 {
+			return "Teams must play from different sides"
+		}
+		if len(side1) > maxSideLength || len(side2) > maxSideLength {
+			return "Side must be at most 20 characters"
+		}
+	}
+	return ""
+}
+
 func (h *Handler) UpdateGame(w http.ResponseWriter, r *http.Request) {
 	gameID := chi.URLParam(r, "id")
 	if gameID == "" {
@@ -124,20 +370,38 @@ func (h *Handler) UpdateGame(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Team1Score int `json:"team1_score"`
-		Team2Score int `json:"team2_score"`
+		Team1Score int           `json:"team1_score"`
+		Team2Score int           `json:"team2_score"`
+		Goals      []models.Goal `json:"goals,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	err := h.repo.UpdateGame(r.Context(), gameID, req.Team1Score, req.Team2Score)
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	game, err := h.repo.UpdateGame(r.Context(), gameID, req.Team1Score, req.Team2Score, req.Goals, expectedVersion)
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		respondError(w, http.StatusPreconditionFailed, "Game was modified by someone else")
+		return
+	}
+	if errors.Is(err, ledger.ErrInvalidGoals) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
+		logError(r, "Failed to update game", err)
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	setETag(w, game.Version)
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Game updated and ratings recalculated"})
 }
 
@@ -148,8 +412,19 @@ func (h *Handler) DeletePlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.repo.DeletePlayer(r.Context(), playerID)
+	expectedVersion, err := parseIfMatch(r)
 	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	err = h.repo.DeletePlayer(r.Context(), playerID, expectedVersion)
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		respondError(w, http.StatusPreconditionFailed, "Player was modified by someone else")
+		return
+	}
+	if err != nil {
+		logError(r, "Failed to delete player", err)
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -177,12 +452,25 @@ func (h *Handler) UpdatePlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.repo.UpdatePlayer(r.Context(), playerID, req.Name)
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	player, err := h.repo.UpdatePlayer(r.Context(), playerID, req.Name, expectedVersion)
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		respondError(w, http.StatusPreconditionFailed, "Player was modified by someone else")
+		return
+	}
 	if err != nil {
+		logError(r, "Failed to update player", err)
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	setETag(w, player.Version)
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Player updated"})
 }
 
@@ -200,9 +488,27 @@ func (h *Handler) GetPlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, player.Version)
 	respondJSON(w, http.StatusOK, player)
 }
 
+func (h *Handler) GetGame(w http.ResponseWriter, r *http.Request) {
+	gameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	game, err := h.repo.GetGameByID(r.Context(), gameID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	setETag(w, game.Version)
+	respondJSON(w, http.StatusOK, game)
+}
+
 func (h *Handler) GetPlayerStats(w http.ResponseWriter, r *http.Request) {
 	playerIDStr := chi.URLParam(r, "id")
 	playerID, err := strconv.Atoi(playerIDStr)
@@ -211,9 +517,15 @@ func (h *Handler) GetPlayerStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats, err := h.repo.GetPlayerStats(r.Context(), playerID)
+	tableID, err := parseIntParam(r.URL.Query().Get("table_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid table ID")
+		return
+	}
+
+	stats, err := h.repo.GetPlayerStats(r.Context(), playerID, tableID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch player stats")
+		respondServerError(w, r, "Failed to fetch player stats", err)
 		return
 	}
 
@@ -230,7 +542,7 @@ func (h *Handler) GetPlayerHeadToHead(w http.ResponseWriter, r *http.Request) {
 
 	headToHead, err := h.repo.GetPlayerHeadToHead(r.Context(), playerID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch head-to-head data")
+		respondServerError(w, r, "Failed to fetch head-to-head data", err)
 		return
 	}
 
@@ -247,7 +559,7 @@ func (h *Handler) GetPlayerRatingHistory(w http.ResponseWriter, r *http.Request)
 
 	history, err := h.repo.GetPlayerRatingHistory(r.Context(), playerID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch rating history")
+		respondServerError(w, r, "Failed to fetch rating history", err)
 		return
 	}
 
@@ -264,7 +576,7 @@ func (h *Handler) GetPlayerRecentGames(w http.ResponseWriter, r *http.Request) {
 
 	games, err := h.repo.GetPlayerRecentGames(r.Context(), playerID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch recent games")
+		respondServerError(w, r, "Failed to fetch recent games", err)
 		return
 	}
 
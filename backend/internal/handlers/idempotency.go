@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotent lets clients safely retry a create request by sending an
+// Idempotency-Key header. The first response for a key is stored and replayed
+// for any repeat of the same request until the key expires after ttl.
+func (h *Handler) Idempotent(ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				respondError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			hash := hex.EncodeToString(sum[:])
+
+			idem := models.IdempotencyKey{
+				Caller: idempotencyCaller(r),
+				Key:    key,
+				Method: r.Method,
+				Route:  idempotencyRoute(r),
+			}
+			existing, err := h.repo.ReserveIdempotencyKey(r.Context(), idem, hash, ttl)
+			if err != nil {
+				respondServerError(w, r, "Failed to check idempotency key", err)
+				return
+			}
+			if existing != nil {
+				switch {
+				case existing.RequestHash != hash:
+					respondError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+				case existing.StatusCode == nil:
+					respondError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+				default:
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(*existing.StatusCode)
+					w.Write(existing.ResponseBody)
+				}
+				return
+			}
+
+			// The response has already been sent, so store it even if the
+			// client has gone away.
+			ctx := context.WithoutCancel(r.Context())
+
+			// A handler that panics never finishes the request, so free the
+			// key for a retry before passing the panic on; otherwise every
+			// retry would be told the request is still in progress.
+			defer func() {
+				if p := recover(); p != nil {
+					if err := h.repo.ReleaseIdempotencyKey(ctx, idem); err != nil {
+						logError(r, "Failed to release idempotency key", err, "idempotency_key", key)
+					}
+					panic(p)
+				}
+			}()
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= http.StatusInternalServerError {
+				err = h.repo.ReleaseIdempotencyKey(ctx, idem)
+			} else {
+				err = h.repo.CompleteIdempotencyKey(ctx, idem, rec.status, rec.body.Bytes())
+			}
+			if err != nil {
+				logError(r, "Failed to store idempotent response", err, "idempotency_key", key)
+			}
+		})
+	}
+}
+
+// idempotencyCaller names whoever sent a request, so that clients choosing
+// the same key do not share it. Anonymous callers all share one name.
+func idempotencyCaller(r *http.Request) string {
+	c := callerFromContext(r.Context())
+	switch {
+	case c == nil:
+		return ""
+	case c.key != nil:
+		return "api-key:" + strconv.Itoa(c.key.ID)
+	case c.user != nil:
+		return "user:" + strconv.Itoa(c.user.ID)
+	}
+	return ""
+}
+
+// idempotencyRoute is the route a request matched, without the organization
+// prefix, so a retry sent to /api/games finds a key first used on
+// /api/orgs/{org}/games. The organization is part of the key already.
+func idempotencyRoute(r *http.Request) string {
+	pattern := chi.RouteContext(r.Context()).RoutePattern()
+	return strings.Replace(pattern, "/orgs/{org}", "", 1)
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultEventsLimit = 500
+	maxEventsLimit     = 5000
+)
+
+func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var afterID int64
+	if v := query.Get("after"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			respondError(w, http.StatusBadRequest, "Invalid 'after' event ID")
+			return
+		}
+		afterID = id
+	}
+
+	limit := defaultEventsLimit
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > maxEventsLimit {
+			respondError(w, http.StatusBadRequest, "Limit must be between 1 and 5000")
+			return
+		}
+		limit = l
+	}
+
+	events, err := h.repo.ListEvents(r.Context(), afterID, limit)
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch events", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, events)
+}
+
+func (h *Handler) RebuildProjections(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.RebuildProjections(r.Context()); err != nil {
+		respondServerError(w, r, "Failed to rebuild projections", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Projections rebuilt from event ledger"})
+}
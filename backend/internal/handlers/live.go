@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/stream"
+)
+
+// Events sent on a live game's stream. The stream ends after
+// liveGameFinished or liveGameAbandoned.
+const (
+	liveGameScore     = "score"
+	liveGameFinished  = "finished"
+	liveGameAbandoned = "abandoned"
+)
+
+func liveGameTopic(liveGameID int) string {
+	return fmt.Sprintf("live-games/%d", liveGameID)
+}
+
+// publishLive sends v to everyone watching a live game. Updates are
+// published after they commit, so two close together may arrive out of
+// order; watchers should keep the state with the highest version.
+func (h *Handler) publishLive(liveGameID int, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	h.live.Publish(liveGameTopic(liveGameID), stream.Message{Event: event, Data: data})
+}
+
+func (h *Handler) ListLiveGames(w http.ResponseWriter, r *http.Request) {
+	games, err := h.repo.ListLiveGames(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch live games", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, games)
+}
+
+func (h *Handler) StartLiveGame(w http.ResponseWriter, r *http.Request) {
+	var req models.StartLiveGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if msg := validateGameSetup(r, req.GameType, req.Teams); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	game, err := h.repo.StartLiveGame(r.Context(), req)
+	if err != nil {
+		respondServerError(w, r, "Failed to start live game", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, game)
+}
+
+func (h *Handler) GetLiveGame(w http.ResponseWriter, r *http.Request) {
+	liveGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid live game ID")
+		return
+	}
+
+	game, err := h.repo.GetLiveGame(r.Context(), liveGameID)
+	if errors.Is(err, repository.ErrLiveGameNotFound) {
+		respondError(w, http.StatusNotFound, "Live game not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch live game", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, game)
+}
+
+func (h *Handler) AddLiveGoal(w http.ResponseWriter, r *http.Request) {
+	liveGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid live game ID")
+		return
+	}
+
+	var goal models.Goal
+	if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	game, err := h.repo.AddLiveGoal(r.Context(), liveGameID, goal)
+	h.respondLiveGame(w, r, game, err)
+}
+
+func (h *Handler) UndoLiveGoal(w http.ResponseWriter, r *http.Request) {
+	liveGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid live game ID")
+		return
+	}
+
+	game, err := h.repo.UndoLiveGoal(r.Context(), liveGameID)
+	h.respondLiveGame(w, r, game, err)
+}
+
+// respondLiveGame answers a change to a live game's goals and tells its
+// watchers about the new score.
+func (h *Handler) respondLiveGame(w http.ResponseWriter, r *http.Request, game *models.LiveGame, err error) {
+	if errors.Is(err, repository.ErrLiveGameNotFound) {
+		respondError(w, http.StatusNotFound, "Live game not found")
+		return
+	}
+	if errors.Is(err, ledger.ErrInvalidGoals) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to update live game", err)
+		return
+	}
+
+	h.publishLive(game.ID, liveGameScore, game)
+	respondJSON(w, http.StatusOK, game)
+}
+
+func (h *Handler) FinishLiveGame(w http.ResponseWriter, r *http.Request) {
+	liveGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid live game ID")
+		return
+	}
+
+	live, err := h.repo.GetLiveGame(r.Context(), liveGameID)
+	if errors.Is(err, repository.ErrLiveGameNotFound) {
+		respondError(w, http.StatusNotFound, "Live game not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch live game", err)
+		return
+	}
+
+	r = withGamePlayers(r, live.Teams)
+
+	// The losing team is the one the score says now; finishing at this
+	// version keeps a goal scored meanwhile from changing who it is.
+	var result interface{}
+	status := http.StatusCreated
+	if h.needsConfirmation(r, live.Teams) {
+		result, err = h.repo.SubmitLiveGame(r.Context(), liveGameID, live.Version)
+		status = http.StatusAccepted
+	} else {
+		result, err = h.repo.FinishLiveGame(r.Context(), liveGameID, live.Version)
+	}
+	if errors.Is(err, repository.ErrLiveGameNotFound) {
+		respondError(w, http.StatusNotFound, "Live game not found")
+		return
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		respondError(w, http.StatusConflict, "Live game was scored while finishing it; try again")
+		return
+	}
+	if errors.Is(err, ledger.ErrInvalidGoals) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to finish live game", err)
+		return
+	}
+
+	h.publishLive(liveGameID, liveGameFinished, result)
+	respondJSON(w, status, result)
+}
+
+func (h *Handler) AbandonLiveGame(w http.ResponseWriter, r *http.Request) {
+	liveGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid live game ID")
+		return
+	}
+
+	err = h.repo.AbandonLiveGame(r.Context(), liveGameID)
+	if errors.Is(err, repository.ErrLiveGameNotFound) {
+		respondError(w, http.StatusNotFound, "Live game not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to abandon live game", err)
+		return
+	}
+
+	h.publishLive(liveGameID, liveGameAbandoned, map[string]int{"id": liveGameID})
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Live game abandoned"})
+}
+
+// StreamLiveGame sends the live game's current state as Server-Sent Events,
+// then every change to it until it is finished or abandoned.
+func (h *Handler) StreamLiveGame(w http.ResponseWriter, r *http.Request) {
+	liveGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid live game ID")
+		return
+	}
+
+	// Subscribe before reading the current state so no change made in
+	// between is missed.
+	messages, unsubscribe := h.live.Subscribe(liveGameTopic(liveGameID))
+	defer unsubscribe()
+
+	game, err := h.repo.GetLiveGame(r.Context(), liveGameID)
+	if errors.Is(err, repository.ErrLiveGameNotFound) {
+		respondError(w, http.StatusNotFound, "Live game not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch live game", err)
+		return
+	}
+	data, err := json.Marshal(game)
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch live game", err)
+		return
+	}
+
+	send, ok := openStream(w, r)
+	if !ok {
+		return
+	}
+	if send(stream.Message{Event: liveGameScore, Data: data}) != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if send(stream.Message{}) != nil {
+				return
+			}
+		case batch, ok := <-messages:
+			if !ok {
+				return
+			}
+			for _, msg := range batch {
+				if send(msg) != nil || msg.Event != liveGameScore {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/logging"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+	gameFormats = []string{"singles", "doubles"}
+)
+
+const (
+	defaultConfirmationHours = 48
+	maxConfirmationHours     = 30 * 24
+)
+
+// Organization scopes the request to the organization named by the {org}
+// path prefix or, failing that, the X-Organization header. Requests naming
+// neither belong to the default organization.
+func (h *Handler) Organization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "org")
+		if slug == "" {
+			slug = r.Header.Get("X-Organization")
+		}
+		if slug == "" {
+			slug = repository.DefaultOrganization
+		}
+
+		org, err := h.repo.GetOrganizationBySlug(r.Context(), slug)
+		if errors.Is(err, repository.ErrOrganizationNotFound) {
+			respondError(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		if err != nil {
+			respondServerError(w, r, "Failed to load organization", err)
+			return
+		}
+
+		ctx := repository.WithOrganization(r.Context(), org)
+		ctx = logging.With(ctx, "organization", org.Slug)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (h *Handler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.repo.ListOrganizations(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch organizations", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, orgs)
+}
+
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !slugPattern.MatchString(req.Slug) {
+		respondError(w, http.StatusBadRequest, "Slug must be lowercase letters, digits and hyphens")
+		return
+	}
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if msg := validateSettings(&req.OrganizationSettings); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	org, err := h.repo.CreateOrganization(r.Context(), req)
+	if errors.Is(err, repository.ErrOrganizationExists) {
+		respondError(w, http.StatusConflict, "Organization already exists")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to create organization", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, org)
+}
+
+func (h *Handler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, _ := repository.OrganizationFromContext(r.Context())
+	setETag(w, org.Version)
+	respondJSON(w, http.StatusOK, org)
+}
+
+func (h *Handler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+		models.OrganizationSettings
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if msg := validateSettings(&req.OrganizationSettings); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	org, err := h.repo.UpdateOrganization(r.Context(), req.Name, req.OrganizationSettings, expectedVersion)
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		respondError(w, http.StatusPreconditionFailed, "Organization was modified by someone else")
+		return
+	}
+	if err != nil {
+		logError(r, "Failed to update organization", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, org.Version)
+	respondJSON(w, http.StatusOK, org)
+}
+
+// validateSettings fills in defaults for omitted settings and returns a
+// message describing the first invalid one, or "" if they are all valid.
+func validateSettings(s *models.OrganizationSettings) string {
+	if s.RatingSystem == "" {
+		s.RatingSystem = elo.SystemElo
+	}
+	if s.KFactor == 0 {
+		s.KFactor = elo.KFactor
+	}
+	if s.GameFormats == nil {
+		s.GameFormats = gameFormats
+	}
+	if s.ConfirmationHours == 0 {
+		s.ConfirmationHours = defaultConfirmationHours
+	}
+	if s.AnonymousRole == "" {
+		s.AnonymousRole = repository.RoleNone
+	}
+
+	if !slices.Contains(elo.Systems, s.RatingSystem) {
+		return "Rating system must be 'elo' or 'margin_elo'"
+	}
+	if s.KFactor < 1 || s.KFactor > 100 {
+		return "K factor must be between 1 and 100"
+	}
+	if len(s.GameFormats) == 0 {
+		return "At least one game format is required"
+	}
+	for i, format := range s.GameFormats {
+		if !slices.Contains(gameFormats, format) {
+			return "Game formats must be 'singles' or 'doubles'"
+		}
+		if slices.Contains(s.GameFormats[:i], format) {
+			return "Game formats must not repeat"
+		}
+	}
+	if s.ConfirmationHours < 1 || s.ConfirmationHours > maxConfirmationHours {
+		return "Confirmation hours must be between 1 and 720"
+	}
+	if s.AnonymousRole != repository.RoleNone && !slices.Contains(repository.Roles, s.AnonymousRole) {
+		return "Anonymous role must be 'viewer', 'recorder', 'admin' or 'none'"
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+var pendingStatuses = []string{repository.PendingStatusPending, repository.PendingStatusConfirmed, repository.PendingStatusRejected}
+
+// needsConfirmation reports whether a game about to be recorded should wait
+// for the losing team to confirm it: the organization asks for that, and
+// the caller is not a signed-in player on the losing team already.
+func (h *Handler) needsConfirmation(r *http.Request, teams []models.CreateGameTeam) bool {
+	org, ok := repository.OrganizationFromContext(r.Context())
+	if !ok || !org.RequireConfirmation {
+		return false
+	}
+	return !callerPlaysFor(r, repository.LosingPlayerIDs(teams))
+}
+
+func (h *Handler) ListPendingGames(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = repository.PendingStatusPending
+	}
+	if !slices.Contains(pendingStatuses, status) {
+		respondError(w, http.StatusBadRequest, "Status must be 'pending', 'confirmed' or 'rejected'")
+		return
+	}
+
+	limit := defaultGamesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxGamesLimit {
+			respondError(w, http.StatusBadRequest, "Limit must be between 1 and 200")
+			return
+		}
+	}
+
+	games, err := h.repo.ListPendingGames(r.Context(), status, limit)
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch pending games", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, games)
+}
+
+func (h *Handler) ConfirmPendingGame(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.resolvablePendingGame(w, r)
+	if !ok {
+		return
+	}
+
+	game, err := h.repo.ConfirmPendingGame(r.Context(), pending.ID)
+	if errors.Is(err, repository.ErrPendingGameNotFound) {
+		respondError(w, http.StatusConflict, "Game is no longer pending")
+		return
+	}
+	if err != nil {
+		logError(r, "Failed to confirm game", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, game)
+}
+
+func (h *Handler) RejectPendingGame(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.resolvablePendingGame(w, r)
+	if !ok {
+		return
+	}
+
+	pending, err := h.repo.RejectPendingGame(r.Context(), pending.ID)
+	if errors.Is(err, repository.ErrPendingGameNotFound) {
+		respondError(w, http.StatusConflict, "Game is no longer pending")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to reject game", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, pending)
+}
+
+// resolvablePendingGame loads the pending game named in the URL and checks
+// the caller may confirm or reject it: they are signed in as a player on
+// the losing team, or are an admin. Otherwise it responds with the problem.
+func (h *Handler) resolvablePendingGame(w http.ResponseWriter, r *http.Request) (*models.PendingGame, bool) {
+	pendingGameID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pending game ID")
+		return nil, false
+	}
+
+	pending, err := h.repo.GetPendingGame(r.Context(), pendingGameID)
+	if errors.Is(err, repository.ErrPendingGameNotFound) {
+		respondError(w, http.StatusNotFound, "Pending game not found")
+		return nil, false
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch pending game", err)
+		return nil, false
+	}
+	if pending.Status != repository.PendingStatusPending {
+		respondError(w, http.StatusConflict, "Game is no longer pending")
+		return nil, false
+	}
+
+	if !h.requirePlayerOrAdmin(w, r, pending.ConfirmableBy, "Only a player on the losing team can confirm or reject this game") {
+		return nil, false
+	}
+	return pending, true
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+func (h *Handler) SideAdvantage(w http.ResponseWriter, r *http.Request) {
+	tableID, err := parseIntParam(r.URL.Query().Get("table_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid table ID")
+		return
+	}
+
+	report, err := h.repo.GetSideAdvantage(r.Context(), tableID)
+	if errors.Is(err, repository.ErrTableNotFound) {
+		respondError(w, http.StatusNotFound, "Table not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch side advantage", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/gametext"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const (
+	// slackMaxSkew is how old a request's timestamp may be before it is
+	// rejected as a possible replay.
+	slackMaxSkew        = 5 * time.Minute
+	maxSlackCommandSize = 64 << 10
+	slackRankLimit      = 10
+)
+
+const slackUsage = "Usage:\n" +
+	"• `/foos @alice beat @bob 10-7` records a singles game\n" +
+	"• `/foos @alice @bob beat @carol @dan 10-7` or `/foos alice & bob 10-7 carol & dan` records a doubles game\n" +
+	"• `/foos rank` shows the leaderboard\n" +
+	"• `/foos link <player name>` links you to a player"
+
+// slackResponse is the reply to a slash command. In-channel replies are
+// shown to everyone; ephemeral ones only to whoever ran the command.
+type slackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// SlackCommand answers Slack slash commands. Each reply is sent with status
+// 200, as Slack only shows the text of successful responses.
+func (h *Handler) SlackCommand(w http.ResponseWriter, r *http.Request) {
+	if h.config.SlackSigningSecret == "" {
+		respondError(w, http.StatusServiceUnavailable, "Slack integration is not configured")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackCommandSize))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !verifySlackSignature(h.config.SlackSigningSecret, r.Header, body, time.Now()) {
+		respondError(w, http.StatusUnauthorized, "Invalid Slack signature")
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if name := form.Get("user_name"); name != "" {
+		r = r.WithContext(repository.WithActor(r.Context(), "slack:"+name))
+	}
+
+	text := strings.TrimSpace(form.Get("text"))
+	fields := strings.Fields(text)
+	switch {
+	case len(fields) == 0 || strings.EqualFold(fields[0], "help"):
+		respondSlack(w, false, slackUsage)
+	case strings.EqualFold(fields[0], "rank") || strings.EqualFold(fields[0], "leaderboard"):
+		h.slackRank(w, r)
+	case strings.EqualFold(fields[0], "link"):
+		h.slackLink(w, r, form, strings.TrimSpace(text[len(fields[0]):]))
+	default:
+		h.slackRecordGame(w, r, text)
+	}
+}
+
+// verifySlackSignature checks the X-Slack-Signature header, an HMAC of the
+// timestamp and body keyed with the app's signing secret, and that the
+// request is recent.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) bool {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature")))
+}
+
+func respondSlack(w http.ResponseWriter, inChannel bool, text string) {
+	responseType := "ephemeral"
+	if inChannel {
+		responseType = "in_channel"
+	}
+	respondJSON(w, http.StatusOK, slackResponse{ResponseType: responseType, Text: text})
+}
+
+func (h *Handler) slackRank(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.repo.GetLeaderboard(r.Context())
+	if err != nil {
+		logError(r, "Failed to fetch the leaderboard", err)
+		respondSlack(w, false, "Failed to fetch the leaderboard")
+		return
+	}
+	if len(entries) == 0 {
+		respondSlack(w, false, "No players have been ranked yet")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("*Leaderboard*")
+	for i, entry := range entries {
+		if i == slackRankLimit {
+			break
+		}
+		fmt.Fprintf(&b, "\n%d. %s %d (%d-%d)", i+1, entry.Name, entry.Rating, entry.Wins, entry.Losses)
+	}
+	respondSlack(w, true, b.String())
+}
+
+// slackLink links whoever ran the command, by user ID and username, to the
+// player with the given name.
+func (h *Handler) slackLink(w http.ResponseWriter, r *http.Request, form url.Values, name string) {
+	userID, userName := form.Get("user_id"), form.Get("user_name")
+	if name == "" || userID == "" {
+		respondSlack(w, false, "Usage: `/foos link <player name>`")
+		return
+	}
+
+	players, err := h.gameTextPlayers(r.Context())
+	if err != nil {
+		logError(r, "Failed to fetch players", err)
+		respondSlack(w, false, "Failed to fetch players")
+		return
+	}
+	player, err := gametext.Match(name, players)
+	if err != nil {
+		respondSlack(w, false, "Could not link you: "+err.Error())
+		return
+	}
+
+	for _, handle := range []string{userID, userName} {
+		if handle == "" {
+			continue
+		}
+		if _, err := h.repo.SetChatHandle(r.Context(), handle, player.ID); err != nil {
+			logError(r, "Failed to link chat handle", err, "chat_handle", handle, "player_id", player.ID)
+			respondSlack(w, false, "Failed to link your handle")
+			return
+		}
+	}
+	respondSlack(w, false, fmt.Sprintf("You are now linked to %s", player.Name))
+}
+
+func (h *Handler) slackRecordGame(w http.ResponseWriter, r *http.Request, text string) {
+	players, err := h.gameTextPlayers(r.Context())
+	if err != nil {
+		logError(r, "Failed to fetch players", err)
+		respondSlack(w, false, "Failed to fetch players")
+		return
+	}
+
+	result, err := gametext.Parse(text, players)
+	var matchErr *gametext.MatchError
+	if errors.As(err, &matchErr) {
+		respondSlack(w, false, "Could not tell who played: "+err.Error()+
+			"\nPlayers can run `/foos link <player name>` to link their handle.")
+		return
+	}
+	if err != nil {
+		respondSlack(w, false, err.Error()+"\n"+slackUsage)
+		return
+	}
+
+	req := result.Request
+	if msg := validateGameRequest(r, &req); msg != "" {
+		respondSlack(w, false, msg)
+		return
+	}
+	r = withGamePlayers(r, req.Teams)
+
+	if h.needsConfirmation(r, req.Teams) {
+		pending, err := h.repo.SubmitGame(r.Context(), req)
+		if err != nil {
+			logError(r, "Failed to submit game", err)
+			respondSlack(w, false, "Failed to submit the game: "+err.Error())
+			return
+		}
+		respondSlack(w, true, formatSlackPending(result, pending))
+		return
+	}
+
+	game, err := h.repo.CreateGame(r.Context(), req)
+	if err != nil {
+		logError(r, "Failed to record game", err)
+		respondSlack(w, false, "Failed to record the game: "+err.Error())
+		return
+	}
+	respondSlack(w, true, formatSlackGame(game))
+}
+
+// formatSlackPending describes a game waiting for the losing team to
+// confirm it.
+func formatSlackPending(result *gametext.Result, pending *models.PendingGame) string {
+	var names [2][]string
+	for i, team := range result.Teams {
+		for _, p := range team {
+			names[i] = append(names[i], p.Name)
+		}
+	}
+	teams := result.Request.Teams
+	winner := 0
+	if teams[1].Score > teams[0].Score {
+		winner = 1
+	}
+	confirmers := names[1-winner]
+	if teams[0].Score == teams[1].Score {
+		confirmers = append(names[0], names[1]...)
+	}
+
+	return fmt.Sprintf("%s %d-%d %s is waiting for %s to confirm it (game %d). Unless it is rejected, it counts from %s.",
+		strings.Join(names[winner], " & "), teams[winner].Score, teams[1-winner].Score, strings.Join(names[1-winner], " & "),
+		strings.Join(confirmers, " or "), pending.ID, pending.ConfirmBy.Format("Jan 2 15:04 MST"))
+}
+
+func formatSlackGame(game *models.Game) string {
+	var teams [2][]string
+	var scores [2]int
+	for _, p := range game.Players {
+		teams[p.Team-1] = append(teams[p.Team-1], p.PlayerName)
+		scores[p.Team-1] = p.Score
+	}
+	winner := 0
+	if scores[1] > scores[0] {
+		winner = 1
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s beat %s %d-%d", strings.Join(teams[winner], " & "), strings.Join(teams[1-winner], " & "),
+		scores[winner], scores[1-winner])
+	for _, p := range game.Players {
+		fmt.Fprintf(&b, "\n• %s: %d → %d (%+d)", p.PlayerName, p.RatingBefore, p.RatingAfter, p.RatingAfter-p.RatingBefore)
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/stream"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment so proxies
+// do not time it out.
+const keepAliveInterval = 15 * time.Second
+
+// StreamChanges sends the organization's changes as Server-Sent Events as
+// they are committed. Repeat player_id to only receive the changes that
+// concern those players. A client that cannot keep up is disconnected rather
+// than sent an incomplete stream.
+func (h *Handler) StreamChanges(w http.ResponseWriter, r *http.Request) {
+	var players []int
+	for _, value := range r.URL.Query()["player_id"] {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid player ID")
+			return
+		}
+		players = append(players, id)
+	}
+
+	messages, unsubscribe := h.repo.SubscribeChanges(r.Context())
+	defer unsubscribe()
+
+	send, ok := openStream(w, r)
+	if !ok {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if send(stream.Message{}) != nil {
+				return
+			}
+		case batch, ok := <-messages:
+			if !ok {
+				// Too far behind to be sent everything; end the stream so
+				// the client reconnects and reloads.
+				return
+			}
+			for _, msg := range batch {
+				if len(players) > 0 && !slices.ContainsFunc(msg.Players, func(id int) bool { return slices.Contains(players, id) }) {
+					continue
+				}
+				if send(msg) != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// openStream starts a text/event-stream response that is exempt from the
+// server's write timeout and returns a function that sends and flushes one
+// message. An empty message is sent as a comment, to keep the connection
+// alive.
+func openStream(w http.ResponseWriter, r *http.Request) (func(stream.Message) error, bool) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		respondServerError(w, r, "Streaming not supported", err)
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return nil, false
+	}
+
+	return func(msg stream.Message) error {
+		var err error
+		if msg.Event == "" && msg.Data == nil {
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		} else {
+			err = stream.Write(w, msg)
+		}
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}, true
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const maxKMultiplier = 4
+
+func (h *Handler) ListTables(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.repo.ListTables(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch tables", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, tables)
+}
+
+func (h *Handler) CreateTable(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeTableRequest(w, r)
+	if !ok {
+		return
+	}
+
+	table, err := h.repo.CreateTable(r.Context(), req)
+	if errors.Is(err, repository.ErrTableExists) {
+		respondError(w, http.StatusConflict, "A table with that name already exists")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to create table", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, table)
+}
+
+func (h *Handler) GetTable(w http.ResponseWriter, r *http.Request) {
+	tableID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid table ID")
+		return
+	}
+
+	table, err := h.repo.GetTableByID(r.Context(), tableID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Table not found")
+		return
+	}
+
+	setETag(w, table.Version)
+	respondJSON(w, http.StatusOK, table)
+}
+
+func (h *Handler) UpdateTable(w http.ResponseWriter, r *http.Request) {
+	tableID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid table ID")
+		return
+	}
+
+	req, ok := decodeTableRequest(w, r)
+	if !ok {
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	table, err := h.repo.UpdateTable(r.Context(), tableID, req, expectedVersion)
+	switch {
+	case errors.Is(err, repository.ErrTableNotFound):
+		respondError(w, http.StatusNotFound, "Table not found")
+		return
+	case errors.Is(err, repository.ErrTableExists):
+		respondError(w, http.StatusConflict, "A table with that name already exists")
+		return
+	case errors.Is(err, repository.ErrVersionMismatch):
+		respondError(w, http.StatusPreconditionFailed, "Table was modified by someone else")
+		return
+	case err != nil:
+		logError(r, "Failed to update table", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, table.Version)
+	respondJSON(w, http.StatusOK, table)
+}
+
+func (h *Handler) GetTableStats(w http.ResponseWriter, r *http.Request) {
+	tableID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid table ID")
+		return
+	}
+
+	stats, err := h.repo.GetTableStats(r.Context(), tableID)
+	if errors.Is(err, repository.ErrTableNotFound) {
+		respondError(w, http.StatusNotFound, "Table not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch table stats", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// decodeTableRequest reads and validates a table body, responding with an
+// error and returning false if it is unusable.
+func decodeTableRequest(w http.ResponseWriter, r *http.Request) (models.TableRequest, bool) {
+	var req models.TableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return req, false
+	}
+
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Name is required")
+		return req, false
+	}
+	if m := req.KMultiplier; m != nil && (*m <= 0 || *m > maxKMultiplier) {
+		respondError(w, http.StatusBadRequest, "K multiplier must be greater than 0 and at most 4")
+		return req, false
+	}
+	return req, true
+}
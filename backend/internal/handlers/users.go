@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+const (
+	maxUsernameLength = 50
+	minPasswordLength = 8
+	// bcrypt only uses the first 72 bytes of a password.
+	maxPasswordLength = 72
+)
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.repo.Authenticate(r.Context(), strings.TrimSpace(req.Username), req.Password)
+	if errors.Is(err, repository.ErrInvalidCredentials) {
+		respondError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to sign in", err)
+		return
+	}
+
+	token, err := h.repo.CreateSession(r.Context(), user.ID, h.config.SessionTTL)
+	if err != nil {
+		respondServerError(w, r, "Failed to sign in", err)
+		return
+	}
+	h.setSessionCookie(w, token)
+	respondJSON(w, http.StatusOK, user)
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		if err := h.repo.DeleteSession(r.Context(), cookie.Value); err != nil {
+			respondServerError(w, r, "Failed to sign out", err)
+			return
+		}
+	}
+	h.clearSessionCookie(w)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Signed out"})
+}
+
+// CurrentUser returns the signed-in user.
+func (h *Handler) CurrentUser(w http.ResponseWriter, r *http.Request) {
+	c := callerFromContext(r.Context())
+	if c == nil || c.user == nil {
+		respondError(w, http.StatusUnauthorized, "Not signed in")
+		return
+	}
+	respondJSON(w, http.StatusOK, c.user)
+}
+
+// ChangePassword changes the signed-in user's password, signing them out
+// of their other sessions.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	c := callerFromContext(r.Context())
+	if c == nil || c.user == nil {
+		respondError(w, http.StatusUnauthorized, "Not signed in")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validatePassword(req.NewPassword); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	cookie, _ := r.Cookie(sessionCookie)
+	err := h.repo.ChangePassword(r.Context(), c.user.ID, req.CurrentPassword, req.NewPassword, cookie.Value)
+	if errors.Is(err, repository.ErrInvalidCredentials) {
+		respondError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to change password", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Password changed"})
+}
+
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.repo.ListUsers(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch users", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, users)
+}
+
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		respondError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+	if len(req.Username) > maxUsernameLength {
+		respondError(w, http.StatusBadRequest, "Username must be at most 50 characters")
+		return
+	}
+	if strings.ContainsAny(req.Username, " \t\r\n") {
+		respondError(w, http.StatusBadRequest, "Username must not contain spaces")
+		return
+	}
+	if msg := validatePassword(req.Password); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.Role == "" {
+		req.Role = repository.RoleRecorder
+	}
+	if msg := h.validateUserAccess(r, req.Role, req.PlayerID); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	user, err := h.repo.CreateUser(r.Context(), req)
+	if errors.Is(err, repository.ErrUserExists) {
+		respondError(w, http.StatusConflict, "A user with that username already exists")
+		return
+	}
+	if errors.Is(err, repository.ErrPlayerLinked) {
+		respondError(w, http.StatusConflict, "Player is already linked to another user")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to create user", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, user)
+}
+
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := h.validateUserAccess(r, req.Role, req.PlayerID); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	user, err := h.repo.UpdateUser(r.Context(), userID, req)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if errors.Is(err, repository.ErrPlayerLinked) {
+		respondError(w, http.StatusConflict, "Player is already linked to another user")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to update user", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, user)
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	err = h.repo.DeleteUser(r.Context(), userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to delete user", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User deleted"})
+}
+
+func validatePassword(password string) string {
+	if len(password) < minPasswordLength {
+		return "Password must be at least 8 characters"
+	}
+	if len(password) > maxPasswordLength {
+		return "Password must be at most 72 bytes"
+	}
+	return ""
+}
+
+// validateUserAccess checks a user's role and the player they are linked
+// to, returning a message describing the first problem found.
+func (h *Handler) validateUserAccess(r *http.Request, role string, playerID *int) string {
+	if !slices.Contains(repository.Roles, role) {
+		return "Role must be 'viewer', 'recorder' or 'admin'"
+	}
+	if playerID != nil {
+		if _, err := h.repo.GetPlayerByID(r.Context(), *playerID); err != nil {
+			return "Player not found"
+		}
+	}
+	return ""
+}
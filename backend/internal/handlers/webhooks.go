@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/webhook"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.repo.ListWebhooks(r.Context())
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch webhooks", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, webhooks)
+}
+
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondError(w, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		return
+	}
+	if webhook.CheckURL(u) != nil {
+		respondError(w, http.StatusBadRequest, "URL must not point to a private or local address")
+		return
+	}
+	if req.Events == nil {
+		req.Events = repository.WebhookEvents
+	}
+	if len(req.Events) == 0 {
+		respondError(w, http.StatusBadRequest, "At least one event is required")
+		return
+	}
+	for i, event := range req.Events {
+		if !slices.Contains(repository.WebhookEvents, event) {
+			respondError(w, http.StatusBadRequest, "Events must be 'game_recorded', 'game_deleted' or 'leader_changed'")
+			return
+		}
+		if slices.Contains(req.Events[:i], event) {
+			respondError(w, http.StatusBadRequest, "Events must not repeat")
+			return
+		}
+	}
+
+	webhook, err := h.repo.CreateWebhook(r.Context(), req)
+	if err != nil {
+		respondServerError(w, r, "Failed to create webhook", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, webhook)
+}
+
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook, err := h.repo.GetWebhook(r.Context(), webhookID)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch webhook", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, webhook)
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	err = h.repo.DeleteWebhook(r.Context(), webhookID)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to delete webhook", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxDeliveriesLimit {
+			respondError(w, http.StatusBadRequest, "Limit must be between 1 and 500")
+			return
+		}
+	}
+
+	deliveries, err := h.repo.ListDeliveries(r.Context(), webhookID, limit)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to fetch deliveries", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.repo.Redeliver(r.Context(), webhookID, deliveryID)
+	if errors.Is(err, repository.ErrDeliveryNotFound) {
+		respondError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if err != nil {
+		respondServerError(w, r, "Failed to queue redelivery", err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, delivery)
+}
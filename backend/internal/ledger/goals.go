@@ -0,0 +1,73 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// ErrInvalidGoals is wrapped by errors describing a goal timeline that does
+// not fit its game.
+var ErrInvalidGoals = errors.New("invalid goals")
+
+var Rods = []string{"goalie", "defense", "midfield", "attack"}
+
+// ScoreGoals checks a game's goal timeline against its two teams, fills in
+// the team each goal counts for and sets each team's score to the goals it
+// was given. A goal counts for the scorer's team, or for the other team when
+// it is an own goal; goals by an unknown scorer must name the team they
+// count for. Scores already set on the teams must agree with the timeline.
+func ScoreGoals(teams []models.CreateGameTeam, goals []models.Goal) error {
+	if len(teams) != 2 {
+		return fmt.Errorf("%w: exactly 2 teams required", ErrInvalidGoals)
+	}
+
+	playerTeam := make(map[int]int)
+	for i, team := range teams {
+		for _, id := range team.PlayerIDs {
+			playerTeam[id] = i + 1
+		}
+	}
+
+	var scores [2]int
+	lastElapsed := 0
+	for i := range goals {
+		g := &goals[i]
+		if g.Rod != "" && !slices.Contains(Rods, g.Rod) {
+			return fmt.Errorf("%w: goal %d: rod must be 'goalie', 'defense', 'midfield' or 'attack'", ErrInvalidGoals, i+1)
+		}
+		if g.ElapsedSeconds != nil {
+			if *g.ElapsedSeconds < lastElapsed {
+				return fmt.Errorf("%w: goal %d: elapsed_seconds must not go backwards", ErrInvalidGoals, i+1)
+			}
+			lastElapsed = *g.ElapsedSeconds
+		}
+
+		if g.PlayerID != nil {
+			team, ok := playerTeam[*g.PlayerID]
+			if !ok {
+				return fmt.Errorf("%w: goal %d: player %d did not play in this game", ErrInvalidGoals, i+1, *g.PlayerID)
+			}
+			if g.OwnGoal {
+				team = 3 - team
+			}
+			if g.Team != 0 && g.Team != team {
+				return fmt.Errorf("%w: goal %d: counts for team %d, not team %d", ErrInvalidGoals, i+1, team, g.Team)
+			}
+			g.Team = team
+		} else if g.Team != 1 && g.Team != 2 {
+			return fmt.Errorf("%w: goal %d: needs a player_id or a team of 1 or 2", ErrInvalidGoals, i+1)
+		}
+		scores[g.Team-1]++
+	}
+
+	for i := range teams {
+		if teams[i].Score != 0 && teams[i].Score != scores[i] {
+			return fmt.Errorf("%w: team %d has a score of %d but %d goals", ErrInvalidGoals, i+1, teams[i].Score, scores[i])
+		}
+		teams[i].Score = scores[i]
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+const (
+	PlayerCreated = "PlayerCreated"
+	PlayerRenamed = "PlayerRenamed"
+	PlayerDeleted = "PlayerDeleted"
+	GameRecorded  = "GameRecorded"
+	GameCorrected = "GameCorrected"
+	GameVoided    = "GameVoided"
+)
+
+type Event struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID int             `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	Actor       string          `json:"actor"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type PlayerCreatedPayload struct {
+	PlayerID  int       `json:"player_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PlayerRenamedPayload struct {
+	PlayerID int    `json:"player_id"`
+	Name     string `json:"name"`
+}
+
+type PlayerDeletedPayload struct {
+	PlayerID int `json:"player_id"`
+}
+
+type GameRecordedPayload struct {
+	GameID    int                     `json:"game_id"`
+	GameType  string                  `json:"game_type"`
+	Teams     []models.CreateGameTeam `json:"teams"`
+	TableID   *int                    `json:"table_id,omitempty"`
+	PlayedAt  *time.Time              `json:"played_at,omitempty"`
+	Goals     []models.Goal           `json:"goals,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// Played returns when the game was played. Games recorded before played_at
+// existed were always entered as they were played.
+func (p GameRecordedPayload) Played() time.Time {
+	if p.PlayedAt != nil {
+		return *p.PlayedAt
+	}
+	return p.CreatedAt
+}
+
+// GameCorrectedPayload replaces a game's scores and, for games with a goal
+// timeline, the goals they were derived from.
+type GameCorrectedPayload struct {
+	GameID     int           `json:"game_id"`
+	Team1Score int           `json:"team1_score"`
+	Team2Score int           `json:"team2_score"`
+	Goals      []models.Goal `json:"goals,omitempty"`
+}
+
+type GameVoidedPayload struct {
+	GameID int `json:"game_id"`
+}
+
+// Projection is the read-model state derived from replaying the ledger.
+type Projection struct {
+	Players     []models.Player
+	Games       []models.Game
+	MaxPlayerID int
+	MaxGameID   int
+}
+
+type playerState struct {
+	player  models.Player
+	deleted bool
+}
+
+type gameState struct {
+	recorded GameRecordedPayload
+	voided   bool
+}
+
+// Project replays events in order and derives players, games and ratings.
+// Games are rated in the order they were played rather than the order they
+// were recorded, so backfilled history lands in the right place. Ratings are
+// computed over every game that has not been voided, including games
+// involving players who were later deleted; deleted players and their
+// participations are then left out of the result, mirroring how deleting a
+// player behaves on the live tables, and their goals are kept without a
+// scorer. Every game is rated with calc, so a change of rating settings
+// applies to the whole history; games on a table listed in tableMultipliers
+// have their K factor scaled by its multiplier.
+// Games with sides recorded are rated with the side advantage learned from
+// the games played before them.
+func Project(events []Event, calc elo.Calculator, tableMultipliers map[int]float64) (*Projection, error) {
+	players := make(map[int]*playerState)
+	var playerOrder []int
+	games := make(map[int]*gameState)
+	var gameOrder []int
+	proj := &Projection{}
+	sides := make(SideRecords)
+
+	for _, e := range events {
+		switch e.Type {
+		case PlayerCreated:
+			var p PlayerCreatedPayload
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return nil, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			players[p.PlayerID] = &playerState{player: models.Player{
+				ID:        p.PlayerID,
+				Name:      p.Name,
+				Rating:    elo.InitialRating,
+				CreatedAt: p.CreatedAt,
+			}}
+			playerOrder = append(playerOrder, p.PlayerID)
+			if p.PlayerID > proj.MaxPlayerID {
+				proj.MaxPlayerID = p.PlayerID
+			}
+		case PlayerRenamed:
+			var p PlayerRenamedPayload
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return nil, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			ps, ok := players[p.PlayerID]
+			if !ok {
+				return nil, fmt.Errorf("event %d: unknown player %d", e.ID, p.PlayerID)
+			}
+			ps.player.Name = p.Name
+		case PlayerDeleted:
+			var p PlayerDeletedPayload
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return nil, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			ps, ok := players[p.PlayerID]
+			if !ok {
+				return nil, fmt.Errorf("event %d: unknown player %d", e.ID, p.PlayerID)
+			}
+			ps.deleted = true
+		case GameRecorded:
+			var p GameRecordedPayload
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return nil, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			if len(p.Teams) != 2 {
+				return nil, fmt.Errorf("event %d: game %d must have exactly 2 teams", e.ID, p.GameID)
+			}
+			for _, team := range p.Teams {
+				for _, id := range team.PlayerIDs {
+					if _, ok := players[id]; !ok {
+						return nil, fmt.Errorf("event %d: unknown player %d", e.ID, id)
+					}
+				}
+			}
+			games[p.GameID] = &gameState{recorded: p}
+			gameOrder = append(gameOrder, p.GameID)
+			if p.GameID > proj.MaxGameID {
+				proj.MaxGameID = p.GameID
+			}
+		case GameCorrected:
+			var p GameCorrectedPayload
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return nil, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			gs, ok := games[p.GameID]
+			if !ok {
+				return nil, fmt.Errorf("event %d: unknown game %d", e.ID, p.GameID)
+			}
+			gs.recorded.Teams[0].Score = p.Team1Score
+			gs.recorded.Teams[1].Score = p.Team2Score
+			if p.Goals != nil {
+				gs.recorded.Goals = p.Goals
+			}
+		case GameVoided:
+			var p GameVoidedPayload
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return nil, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			gs, ok := games[p.GameID]
+			if !ok {
+				return nil, fmt.Errorf("event %d: unknown game %d", e.ID, p.GameID)
+			}
+			gs.voided = true
+		default:
+			return nil, fmt.Errorf("event %d: unknown event type %q", e.ID, e.Type)
+		}
+	}
+
+	sort.SliceStable(gameOrder, func(i, j int) bool {
+		a, b := games[gameOrder[i]].recorded, games[gameOrder[j]].recorded
+		if !a.Played().Equal(b.Played()) {
+			return a.Played().Before(b.Played())
+		}
+		return a.GameID < b.GameID
+	})
+
+	for _, id := range gameOrder {
+		gs := games[id]
+		if gs.voided {
+			continue
+		}
+		gameCalc := calc
+		if table := gs.recorded.TableID; table != nil {
+			if multiplier, ok := tableMultipliers[*table]; ok {
+				gameCalc = calc.Scaled(multiplier)
+			}
+		}
+		proj.Games = append(proj.Games, applyGame(gs.recorded, players, gameCalc, sides))
+	}
+
+	for _, id := range playerOrder {
+		if ps := players[id]; !ps.deleted {
+			proj.Players = append(proj.Players, ps.player)
+		}
+	}
+
+	return proj, nil
+}
+
+func applyGame(g GameRecordedPayload, players map[int]*playerState, calc elo.Calculator, sides SideRecords) models.Game {
+	teamRatings := make([][]int, len(g.Teams))
+	for i, team := range g.Teams {
+		teamRatings[i] = make([]int, len(team.PlayerIDs))
+		for j, id := range team.PlayerIDs {
+			teamRatings[i][j] = players[id].player.Rating
+		}
+	}
+
+	side1, side2 := g.Teams[0].Side, g.Teams[1].Side
+	deltaTeam1, deltaTeam2 := calc.NewRatings(
+		elo.AverageRating(teamRatings[0])+sides.Advantage(side1, side2),
+		elo.AverageRating(teamRatings[1]),
+		g.Teams[0].Score,
+		g.Teams[1].Score,
+	)
+	sides.Record(side1, side2, g.Teams[0].Score > g.Teams[1].Score)
+
+	game := models.Game{ID: g.GameID, GameType: g.GameType, TableID: g.TableID, PlayedAt: g.Played(), CreatedAt: g.CreatedAt, Players: []models.GamePlayer{}}
+	for teamNum, team := range g.Teams {
+		delta := deltaTeam1
+		if teamNum == 1 {
+			delta = deltaTeam2
+		}
+
+		for _, id := range team.PlayerIDs {
+			ps := players[id]
+			before := ps.player.Rating
+			ps.player.Rating += delta
+			ps.player.GamesPlayed++
+
+			if ps.deleted {
+				continue
+			}
+			game.Players = append(game.Players, models.GamePlayer{
+				PlayerID:     id,
+				PlayerName:   ps.player.Name,
+				Team:         teamNum + 1,
+				Side:         team.Side,
+				Score:        team.Score,
+				RatingBefore: before,
+				RatingAfter:  ps.player.Rating,
+			})
+		}
+	}
+
+	for _, goal := range g.Goals {
+		if goal.PlayerID != nil {
+			if ps, ok := players[*goal.PlayerID]; !ok || ps.deleted {
+				goal.PlayerID = nil
+			}
+		}
+		game.Goals = append(game.Goals, goal)
+	}
+	return game
+}
+
+// SideRecords tallies results between pairs of sides, keyed by the pair in
+// alphabetical order with wins counted for the first side.
+type SideRecords map[[2]string]SideRecord
+
+type SideRecord struct {
+	Wins  int
+	Games int
+}
+
+// Advantage returns the rating points side a is worth against side b so far.
+// Games without sides have no advantage.
+func (s SideRecords) Advantage(a, b string) float64 {
+	if a == "" || b == "" || a == b {
+		return 0
+	}
+	if a > b {
+		return -s.Advantage(b, a)
+	}
+	r := s[[2]string{a, b}]
+	return elo.SideAdvantage(r.Wins, r.Games-r.Wins)
+}
+
+// Record adds the result of a game between side a and side b.
+func (s SideRecords) Record(a, b string, aWon bool) {
+	if a == "" || b == "" || a == b {
+		return
+	}
+	if a > b {
+		a, b, aWon = b, a, !aWon
+	}
+	r := s[[2]string{a, b}]
+	r.Games++
+	if aWon {
+		r.Wins++
+	}
+	s[[2]string{a, b}] = r
+}
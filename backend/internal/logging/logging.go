@@ -0,0 +1,124 @@
+// Package logging sets up the structured logger and the middleware that
+// logs every request. Records logged with a request's context carry its
+// request ID, the route it matched and the IDs named in its URL, so
+// handlers and the repository need only log with the context they have.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New returns a logger writing to w in format, "json" or "text", that
+// leaves out records below level: "debug", "info", "warn" or "error".
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: must be 'debug', 'info', 'warn' or 'error'", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be 'json' or 'text'", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+type argsKey struct{}
+
+// With returns a context whose log records carry args, given as to
+// slog.Logger.With, as well as any the context already had.
+func With(ctx context.Context, args ...any) context.Context {
+	existing, _ := ctx.Value(argsKey{}).([]any)
+	return context.WithValue(ctx, argsKey{}, append(existing[:len(existing):len(existing)], args...))
+}
+
+// contextHandler adds what a record's context says about the request being
+// served to the record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		r.AddAttrs(routeAttrs(rctx)...)
+	}
+	if args, ok := ctx.Value(argsKey{}).([]any); ok {
+		r.Add(args...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// idNames name the {id} URL parameter after the path segment before it, so
+// /players/{id} logs player_id and /games/pending/{id} pending_game_id.
+var idNames = map[string]string{
+	"players":    "player_id",
+	"games":      "game_id",
+	"pending":    "pending_game_id",
+	"disputes":   "dispute_id",
+	"live-games": "live_game_id",
+	"tables":     "table_id",
+	"webhooks":   "webhook_id",
+	"users":      "user_id",
+	"api-keys":   "api_key_id",
+}
+
+// paramNames name the other URL parameters.
+var paramNames = map[string]string{
+	"deliveryID": "delivery_id",
+	"handle":     "chat_handle",
+}
+
+// routeAttrs describes the route a request matched, once routing has got
+// far enough to know it, and the parameters in its URL.
+func routeAttrs(rctx *chi.Context) []slog.Attr {
+	route := rctx.RoutePattern()
+	if route == "" {
+		return nil
+	}
+	attrs := []slog.Attr{slog.String("route", route)}
+	for i, key := range rctx.URLParams.Keys {
+		if i >= len(rctx.URLParams.Values) {
+			break
+		}
+		name := paramNames[key]
+		if key == "id" {
+			before, _, _ := strings.Cut(route, "/{id}")
+			name = idNames[before[strings.LastIndex(before, "/")+1:]]
+		}
+		if name == "" {
+			continue
+		}
+		value := rctx.URLParams.Values[i]
+		if n, err := strconv.Atoi(value); err == nil {
+			attrs = append(attrs, slog.Int(name, n))
+		} else {
+			attrs = append(attrs, slog.String(name, value))
+		}
+	}
+	return attrs
+}
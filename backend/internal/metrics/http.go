@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram's buckets: Prometheus's defaults.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute labels requests that matched no route, so paths probed at
+// random do not each get their own series.
+const unmatchedRoute = "unmatched"
+
+type requestKey struct {
+	method, route, status string
+}
+
+type routeKey struct {
+	method, route string
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// httpMetrics counts requests and times them by method and chi route
+// pattern.
+type httpMetrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	latency  map[routeKey]*histogram
+}
+
+func newHTTPMetrics() *httpMetrics {
+	return &httpMetrics{requests: map[requestKey]uint64{}, latency: map[routeKey]*histogram{}}
+}
+
+// Middleware records every request once it has been served. It reads the
+// route pattern chi matched, so it must run inside the router.
+func (e *Exporter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		e.http.record(r.Method, route, status, time.Since(start))
+	})
+}
+
+func (m *httpMetrics) record(method, route string, status int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method, route, strconv.Itoa(status)}]++
+	key := routeKey{method, route}
+	h, ok := m.latency[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.latency[key] = h
+	}
+	h.observe(elapsed.Seconds())
+}
+
+func (m *httpMetrics) write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		requests = append(requests, k)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, c := requests[i], requests[j]
+		if a.route != c.route {
+			return a.route < c.route
+		}
+		if a.method != c.method {
+			return a.method < c.method
+		}
+		return a.status < c.status
+	})
+	header(b, "foosball_http_requests_total", "counter", "HTTP requests served, by method, route and status.")
+	for _, k := range requests {
+		sample(b, "foosball_http_requests_total", float64(m.requests[k]), "method", k.method, "route", k.route, "status", k.status)
+	}
+
+	routes := make([]routeKey, 0, len(m.latency))
+	for k := range m.latency {
+		routes = append(routes, k)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].route != routes[j].route {
+			return routes[i].route < routes[j].route
+		}
+		return routes[i].method < routes[j].method
+	})
+	const name = "foosball_http_request_duration_seconds"
+	header(b, name, "histogram", "Time taken to serve HTTP requests, by method and route.")
+	for _, k := range routes {
+		h := m.latency[k]
+		for i, bound := range latencyBuckets {
+			sample(b, name+"_bucket", float64(h.buckets[i]), "method", k.method, "route", k.route, "le", formatValue(bound))
+		}
+		sample(b, name+"_bucket", float64(h.count), "method", k.method, "route", k.route, "le", formatValue(math.Inf(1)))
+		sample(b, name+"_sum", h.sum, "method", k.method, "route", k.route)
+		sample(b, name+"_count", float64(h.count), "method", k.method, "route", k.route)
+	}
+}
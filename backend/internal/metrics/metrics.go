@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+// domainTimeout bounds the database queries made for one scrape.
+const domainTimeout = 5 * time.Second
+
+// Exporter serves metrics in the Prometheus text format: the HTTP requests
+// its middleware has seen, the database pool's statistics and figures
+// about the games recorded, read from the database at scrape time.
+type Exporter struct {
+	http *httpMetrics
+	pool *pgxpool.Pool
+	repo *repository.Repository
+}
+
+func New(pool *pgxpool.Pool, repo *repository.Repository) *Exporter {
+	return &Exporter{http: newHTTPMetrics(), pool: pool, repo: repo}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var b strings.Builder
+	e.http.write(&b)
+	e.writePool(&b)
+	e.writeDomain(r.Context(), &b)
+	io.WriteString(w, b.String())
+}
+
+func (e *Exporter) writePool(b *strings.Builder) {
+	s := e.pool.Stat()
+	gauge(b, "foosball_db_pool_acquired_connections", "Connections currently in use.", float64(s.AcquiredConns()))
+	gauge(b, "foosball_db_pool_idle_connections", "Connections currently idle in the pool.", float64(s.IdleConns()))
+	gauge(b, "foosball_db_pool_total_connections", "Connections currently open, in use, idle or being opened.", float64(s.TotalConns()))
+	gauge(b, "foosball_db_pool_max_connections", "Most connections the pool will open.", float64(s.MaxConns()))
+	counter(b, "foosball_db_pool_acquires_total", "Connections acquired from the pool.", float64(s.AcquireCount()))
+	counter(b, "foosball_db_pool_empty_acquires_total", "Acquires that had to wait for a connection because none was idle.", float64(s.EmptyAcquireCount()))
+	counter(b, "foosball_db_pool_canceled_acquires_total", "Acquires canceled before a connection was available.", float64(s.CanceledAcquireCount()))
+	counter(b, "foosball_db_pool_acquire_wait_seconds_total", "Time spent acquiring connections, including waiting for one.", s.AcquireDuration().Seconds())
+}
+
+// writeDomain reports each organization's games and players. When the
+// database cannot be read the rest of the metrics are still served, with
+// foosball_domain_metrics_up set to 0.
+func (e *Exporter) writeDomain(ctx context.Context, b *strings.Builder) {
+	ctx, cancel := context.WithTimeout(ctx, domainTimeout)
+	defer cancel()
+
+	totals, err := e.repo.OrganizationTotals(ctx)
+	up := 1.0
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to collect domain metrics", "error", err)
+		up = 0
+	}
+	gauge(b, "foosball_domain_metrics_up", "Whether the game and player figures could be read from the database.", up)
+	if err != nil {
+		return
+	}
+
+	header(b, "foosball_games", "gauge", "Games recorded.")
+	for _, t := range totals {
+		sample(b, "foosball_games", float64(t.Games), "organization", t.Slug)
+	}
+	header(b, "foosball_players", "gauge", "Players registered.")
+	for _, t := range totals {
+		sample(b, "foosball_players", float64(t.Players), "organization", t.Slug)
+	}
+	header(b, "foosball_active_players", "gauge", "Players who have played in the last 30 days.")
+	for _, t := range totals {
+		sample(b, "foosball_active_players", float64(t.ActivePlayers), "organization", t.Slug)
+	}
+	header(b, "foosball_rating_total", "gauge", "Sum of every player's rating.")
+	for _, t := range totals {
+		sample(b, "foosball_rating_total", float64(t.TotalRating), "organization", t.Slug)
+	}
+}
+
+func header(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func gauge(b *strings.Builder, name, help string, value float64) {
+	header(b, name, "gauge", help)
+	sample(b, name, value)
+}
+
+func counter(b *strings.Builder, name, help string, value float64) {
+	header(b, name, "counter", help)
+	sample(b, name, value)
+}
+
+// sample writes one line of a metric, labelled by the name and value pairs
+// in labels.
+func sample(b *strings.Builder, name string, value float64, labels ...string) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatValue(value))
+	b.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -1,26 +1,277 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+type Organization struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	OrganizationSettings
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationSettings control how an organization's games are rated and
+// which game types it records. With RequireConfirmation, games submitted
+// through the API wait for the losing team to confirm them, for at most
+// ConfirmationHours. AnonymousRole is the role of callers with neither a key
+// nor a session, or "none".
+type OrganizationSettings struct {
+	RatingSystem        string   `json:"rating_system"`
+	KFactor             int      `json:"k_factor"`
+	GameFormats         []string `json:"game_formats"`
+	RequireConfirmation bool     `json:"require_confirmation"`
+	ConfirmationHours   int      `json:"confirmation_hours"`
+	AnonymousRole       string   `json:"anonymous_role"`
+}
+
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	OrganizationSettings
+}
+
+// OrganizationTotals are an organization's running totals, as exported to
+// monitoring. ActivePlayers have played in the last 30 days.
+type OrganizationTotals struct {
+	Slug          string
+	Games         int
+	Players       int
+	ActivePlayers int
+	TotalRating   int64
+}
+
+type Table struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Location    string    `json:"location"`
+	KMultiplier float64   `json:"k_multiplier"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type TableRequest struct {
+	Name        string   `json:"name"`
+	Location    string   `json:"location"`
+	KMultiplier *float64 `json:"k_multiplier,omitempty"`
+}
+
+type TableStats struct {
+	TableID      int                `json:"table_id"`
+	GamesPlayed  int                `json:"games_played"`
+	AverageGoals float64            `json:"average_goals"`
+	SideWinRates map[string]float64 `json:"side_win_rates"`
+}
+
+// SideAdvantage compares results between two sides of the table from Side's
+// point of view. Intervals are 95% confidence intervals; RatingOffset is the
+// rating difference the win rate implies and AppliedOffset is what the
+// rating engine currently adds for playing from Side.
+type SideAdvantage struct {
+	Side             string  `json:"side"`
+	OpponentSide     string  `json:"opponent_side"`
+	Games            int     `json:"games"`
+	Wins             int     `json:"wins"`
+	WinRate          float64 `json:"win_rate"`
+	WinRateLow       float64 `json:"win_rate_low"`
+	WinRateHigh      float64 `json:"win_rate_high"`
+	RatingOffset     float64 `json:"rating_offset"`
+	RatingOffsetLow  float64 `json:"rating_offset_low"`
+	RatingOffsetHigh float64 `json:"rating_offset_high"`
+	AppliedOffset    float64 `json:"applied_offset"`
+}
+
+// Webhook is a URL that is sent the organization's events. Secret signs
+// the payloads and is only shown when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	RedeliveryOf   *int64          `json:"redelivery_of"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// ChatHandle links a chat user, by user ID or username, to a player so
+// games can be reported by mentioning them.
+type ChatHandle struct {
+	ID         int       `json:"id"`
+	Handle     string    `json:"handle"`
+	PlayerID   int       `json:"player_id"`
+	PlayerName string    `json:"player_name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ChatHandleRequest struct {
+	Handle   string `json:"handle"`
+	PlayerID int    `json:"player_id"`
+}
+
+// APIKey authenticates requests to one organization with a role. Key is the
+// secret itself, which is only stored hashed and so only shown when the key
+// is issued.
+type APIKey struct {
+	ID             int        `json:"id"`
+	OrganizationID int        `json:"-"`
+	Name           string     `json:"name"`
+	Role           string     `json:"role"`
+	Prefix         string     `json:"prefix"`
+	Key            string     `json:"key,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// User is someone who signs in with a password. Role works as it does for
+// API keys; PlayerID links the user to their own player.
+type User struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"-"`
+	Username       string    `json:"username"`
+	Role           string    `json:"role"`
+	PlayerID       *int      `json:"player_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	PlayerID *int   `json:"player_id"`
+}
+
+type UpdateUserRequest struct {
+	Role     string `json:"role"`
+	PlayerID *int   `json:"player_id"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
 
 type Player struct {
 	ID          int       `json:"id"`
 	Name        string    `json:"name"`
 	Rating      int       `json:"rating"`
 	GamesPlayed int       `json:"games_played"`
+	Version     int       `json:"version"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
 type Game struct {
-	ID        int          `json:"id"`
-	GameType  string       `json:"game_type"`
-	CreatedAt time.Time    `json:"created_at"`
-	Players   []GamePlayer `json:"players"`
+	ID          int            `json:"id"`
+	GameType    string         `json:"game_type"`
+	TableID     *int           `json:"table_id"`
+	Version     int            `json:"version"`
+	PlayedAt    time.Time      `json:"played_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Players     []GamePlayer   `json:"players"`
+	Goals       []Goal         `json:"goals,omitempty"`
+	SubmittedBy *GameSubmitter `json:"submitted_by"`
+	Disputed    bool           `json:"disputed"`
+}
+
+// GameSubmitter is the signed-in user who recorded a game.
+type GameSubmitter struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// PendingGame is a game waiting to be confirmed by one of the players in
+// ConfirmableBy, the losing team, before it is rated. Games still pending
+// at ConfirmBy are confirmed automatically. GameID is the game recorded
+// once it is confirmed.
+type PendingGame struct {
+	ID            int               `json:"id"`
+	Game          CreateGameRequest `json:"game"`
+	ConfirmableBy []int             `json:"confirmable_by"`
+	Status        string            `json:"status"`
+	SubmittedBy   *GameSubmitter    `json:"submitted_by"`
+	SubmittedAt   time.Time         `json:"submitted_at"`
+	ConfirmBy     time.Time         `json:"confirm_by"`
+	ResolvedBy    *string           `json:"resolved_by"`
+	ResolvedAt    *time.Time        `json:"resolved_at"`
+	GameID        *int              `json:"game_id"`
+}
+
+// Dispute challenges a recorded game's result. An admin resolves it by
+// upholding the game, correcting its score or voiding it, which sets
+// Status to "upheld", "corrected" or "voided".
+type Dispute struct {
+	ID                int        `json:"id"`
+	GameID            int        `json:"game_id"`
+	Comment           string     `json:"comment"`
+	OpenedBy          string     `json:"opened_by"`
+	Status            string     `json:"status"`
+	ResolutionComment *string    `json:"resolution_comment"`
+	ResolvedBy        *string    `json:"resolved_by"`
+	ResolvedAt        *time.Time `json:"resolved_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+type DisputeRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ResolveDisputeRequest resolves a dispute. Scores and goals are only used
+// when Resolution is "corrected", as they are by a game correction.
+type ResolveDisputeRequest struct {
+	Resolution string `json:"resolution"`
+	Comment    string `json:"comment"`
+	Team1Score *int   `json:"team1_score,omitempty"`
+	Team2Score *int   `json:"team2_score,omitempty"`
+	Goals      []Goal `json:"goals,omitempty"`
+}
+
+// Goal is one goal in a game's timeline. Team is the team the goal counts
+// for, which for an own goal is the scorer's opponents. PlayerID is nil when
+// the scorer is unknown or has since been deleted.
+type Goal struct {
+	PlayerID       *int   `json:"player_id"`
+	Team           int    `json:"team"`
+	Rod            string `json:"rod,omitempty"`
+	OwnGoal        bool   `json:"own_goal"`
+	ElapsedSeconds *int   `json:"elapsed_seconds,omitempty"`
 }
 
 type GamePlayer struct {
 	PlayerID     int    `json:"player_id"`
 	PlayerName   string `json:"player_name"`
 	Team         int    `json:"team"`
+	Side         string `json:"side,omitempty"`
 	Score        int    `json:"score"`
 	RatingBefore int    `json:"rating_before"`
 	RatingAfter  int    `json:"rating_after"`
@@ -33,11 +284,39 @@ type CreatePlayerRequest struct {
 type CreateGameRequest struct {
 	GameType string           `json:"game_type"`
 	Teams    []CreateGameTeam `json:"teams"`
+	TableID  *int             `json:"table_id,omitempty"`
+	PlayedAt *time.Time       `json:"played_at,omitempty"`
+	Goals    []Goal           `json:"goals,omitempty"`
 }
 
 type CreateGameTeam struct {
-	PlayerIDs []int `json:"player_ids"`
-	Score     int   `json:"score"`
+	PlayerIDs []int  `json:"player_ids"`
+	Score     int    `json:"score"`
+	Side      string `json:"side,omitempty"`
+}
+
+// ParseGameRequest asks for a game result written as free text, such as
+// "Alice & Bob 10-6 Carol & Dan", to be read into a CreateGameRequest.
+type ParseGameRequest struct {
+	Text string `json:"text"`
+}
+
+// LiveGame is a game in progress. Each team's score is the goals it has
+// been given so far; finishing the game records it as a rated game.
+type LiveGame struct {
+	ID        int              `json:"id"`
+	GameType  string           `json:"game_type"`
+	TableID   *int             `json:"table_id"`
+	Teams     []CreateGameTeam `json:"teams"`
+	Goals     []Goal           `json:"goals"`
+	Version   int              `json:"version"`
+	StartedAt time.Time        `json:"started_at"`
+}
+
+type StartLiveGameRequest struct {
+	GameType string           `json:"game_type"`
+	Teams    []CreateGameTeam `json:"teams"`
+	TableID  *int             `json:"table_id,omitempty"`
 }
 
 type LeaderboardEntry struct {
@@ -46,6 +325,23 @@ type LeaderboardEntry struct {
 	Losses int `json:"losses"`
 }
 
+// RankChange reports a player moving on the leaderboard. PreviousRank is
+// nil for players who were not on it before.
+type RankChange struct {
+	PlayerID     int    `json:"player_id"`
+	PlayerName   string `json:"player_name"`
+	Rank         int    `json:"rank"`
+	PreviousRank *int   `json:"previous_rank"`
+	Rating       int    `json:"rating"`
+}
+
+// LeaderChange reports a change of who holds first place on the
+// leaderboard. Several players lead together when their ratings tie.
+type LeaderChange struct {
+	Leaders         []RankChange `json:"leaders"`
+	PreviousLeaders []RankChange `json:"previous_leaders"`
+}
+
 type PlayerStats struct {
 	TotalGames         int     `json:"total_games"`
 	WinRate           float64 `json:"win_rate"`
@@ -55,6 +351,10 @@ type PlayerStats struct {
 	PeakRating        int     `json:"peak_rating"`
 	PeakRatingDate    *time.Time `json:"peak_rating_date"`
 	AvgRatingChange   float64 `json:"avg_rating_change"`
+	GoalsScored       int     `json:"goals_scored"`
+	OwnGoals          int     `json:"own_goals"`
+	GoalsPerGame      float64 `json:"goals_per_game"`
+	Comebacks         int     `json:"comebacks"`
 }
 
 type HeadToHead struct {
@@ -80,3 +380,57 @@ type RecentGame struct {
 	Opponent  string    `json:"opponent"`
 	GameType  string    `json:"game_type"`
 }
+
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   *int
+	Actor      string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
+
+type GameFilter struct {
+	Cursor     string
+	Limit      int
+	PlayerID   *int
+	TeammateID *int
+	OpponentID *int
+	GameType   string
+	TableID    *int
+	From       *time.Time
+	To         *time.Time
+	Score      *[2]int
+	Disputed   bool
+}
+
+type GamePage struct {
+	Games      []Game `json:"games"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// IdempotencyKey identifies a client's request by the Idempotency-Key it
+// sent, who sent it and the route it was sent to.
+type IdempotencyKey struct {
+	Caller string
+	Key    string
+	Method string
+	Route  string
+}
+
+type IdempotencyRecord struct {
+	RequestHash  string
+	StatusCode   *int
+	ResponseBody []byte
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+)
+
+// Roles an API key can have. Each can do everything the ones before it can:
+// viewers read, recorders also add players and record games, and admins
+// also change and delete them and manage the organization.
+const (
+	RoleViewer   = "viewer"
+	RoleRecorder = "recorder"
+	RoleAdmin    = "admin"
+)
+
+// Roles lists the roles from least to most privileged.
+var Roles = []string{RoleViewer, RoleRecorder, RoleAdmin}
+
+// RoleNone is an organization's anonymous role when callers need a key or a
+// session for everything. It is not one of Roles.
+const RoleNone = "none"
+
+// apiKeyPrefix starts every key so leaked keys are easy to recognize.
+const apiKeyPrefix = "foos_"
+
+const apiKeyColumns = `id, organization_id, name, role, prefix, created_at, last_used_at, revoked_at`
+
+// HasRole reports whether role grants at least the privileges of required.
+func HasRole(role, required string) bool {
+	have := slices.Index(Roles, role)
+	return have >= 0 && have >= slices.Index(Roles, required)
+}
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.OrganizationID, &k.Name, &k.Role, &k.Prefix, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// hashToken hashes an API key or session token for storage. Tokens are
+// random, so a plain SHA-256 is enough to look one up by without storing it.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE organization_id = $1 ORDER BY id`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateAPIKey issues a new key, storing only its hash.
+func (r *Repository) CreateAPIKey(ctx context.Context, req models.APIKeyRequest) (*models.APIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	k, err := scanAPIKey(tx.QueryRow(ctx,
+		`INSERT INTO api_keys (organization_id, name, role, prefix, key_hash) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+apiKeyColumns,
+		organizationID(ctx), req.Name, req.Role, key[:len(apiKeyPrefix)+8], hashToken(key),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditCreate, AuditEntityAPIKey, k.ID, nil, k); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	k.Key = key
+	return k, nil
+}
+
+// RevokeAPIKey stops a key from authenticating. The key is kept, marked
+// revoked, so the audit log and key list still make sense.
+func (r *Repository) RevokeAPIKey(ctx context.Context, keyID int) (*models.APIKey, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanAPIKey(tx.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND organization_id = $2 AND revoked_at IS NULL FOR UPDATE`,
+		keyID, organizationID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := scanAPIKey(tx.QueryRow(ctx,
+		`UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 RETURNING `+apiKeyColumns, keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditUpdate, AuditEntityAPIKey, keyID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// AuthenticateAPIKey finds the unrevoked key matching key, in whichever
+// organization it belongs to, and notes that it was used.
+func (r *Repository) AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRow(ctx,
+		`UPDATE api_keys SET last_used_at = NOW() WHERE key_hash = $1 AND revoked_at IS NULL RETURNING `+apiKeyColumns,
+		hashToken(key)))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	return k, err
+}
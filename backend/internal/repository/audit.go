@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+
+	AuditEntityPlayer       = "player"
+	AuditEntityGame         = "game"
+	AuditEntityOrganization = "organization"
+	AuditEntityTable        = "table"
+	AuditEntityWebhook      = "webhook"
+	AuditEntityAPIKey       = "api_key"
+	AuditEntityUser         = "user"
+
+	defaultActor = "anonymous"
+)
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx so read helpers can
+// run inside or outside a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type actorKey struct{}
+
+// WithActor attaches the name of whoever is making the request to ctx so
+// mutations can be attributed in the audit log.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return defaultActor
+}
+
+func recordAudit(ctx context.Context, tx pgx.Tx, action, entityType string, entityID int, before, after interface{}) error {
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO audit_log (organization_id, actor, action, entity_type, entity_id, before, after) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		organizationID(ctx), actorFromContext(ctx), action, entityType, entityID, beforeJSON, afterJSON,
+	)
+	return err
+}
+
+func marshalSnapshot(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func getPlayer(ctx context.Context, q querier, playerID interface{}) (*models.Player, error) {
+	var player models.Player
+	err := q.QueryRow(ctx, `SELECT id, name, rating, games_played, version, created_at FROM players WHERE id = $1 AND organization_id = $2`, playerID, organizationID(ctx)).
+		Scan(&player.ID, &player.Name, &player.Rating, &player.GamesPlayed, &player.Version, &player.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &player, nil
+}
+
+// lockPlayer is getPlayer with the row locked for the rest of tx.
+func lockPlayer(ctx context.Context, tx pgx.Tx, playerID interface{}) (*models.Player, error) {
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM players WHERE id = $1 AND organization_id = $2 FOR UPDATE`, playerID, organizationID(ctx)); err != nil {
+		return nil, err
+	}
+	return getPlayer(ctx, tx, playerID)
+}
+
+// gameSubmitter describes who submitted a game from the nullable
+// submitted_by column and the matching username.
+func gameSubmitter(userID *int, username *string) *models.GameSubmitter {
+	if userID == nil || username == nil {
+		return nil
+	}
+	return &models.GameSubmitter{UserID: *userID, Username: *username}
+}
+
+func getGame(ctx context.Context, q querier, gameID interface{}) (*models.Game, error) {
+	var game models.Game
+	var goals []byte
+	var submitterID *int
+	var submitterName *string
+	err := q.QueryRow(ctx, `SELECT g.id, g.game_type, g.table_id, g.version, g.played_at, g.created_at, g.submitted_by, u.username,
+		 `+disputedColumn("g.id")+`, `+goalsColumn("g.id")+`
+		 FROM games g LEFT JOIN users u ON u.id = g.submitted_by
+		 WHERE g.id = $1 AND g.organization_id = $2`, gameID, organizationID(ctx)).
+		Scan(&game.ID, &game.GameType, &game.TableID, &game.Version, &game.PlayedAt, &game.CreatedAt, &submitterID, &submitterName,
+			&game.Disputed, &goals)
+	if err != nil {
+		return nil, err
+	}
+	game.SubmittedBy = gameSubmitter(submitterID, submitterName)
+	if game.Goals, err = decodeGoals(goals); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(ctx,
+		`SELECT gp.player_id, p.name, gp.team, COALESCE(gp.side, ''), gp.score, gp.rating_before, gp.rating_after
+		 FROM game_participants gp
+		 JOIN players p ON gp.player_id = p.id
+		 WHERE gp.game_id = $1
+		 ORDER BY gp.team, gp.player_id`, game.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	game.Players = []models.GamePlayer{}
+	for rows.Next() {
+		var gp models.GamePlayer
+		if err := rows.Scan(&gp.PlayerID, &gp.PlayerName, &gp.Team, &gp.Side, &gp.Score, &gp.RatingBefore, &gp.RatingAfter); err != nil {
+			return nil, err
+		}
+		game.Players = append(game.Players, gp)
+	}
+	return &game, rows.Err()
+}
+
+func (r *Repository) ListAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	addCondition("organization_id = $%d", organizationID(ctx))
+	if filter.EntityType != "" {
+		addCondition("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		addCondition("entity_id = $%d", *filter.EntityID)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	query := `SELECT id, actor, action, entity_type, entity_id, before, after, created_at FROM audit_log
+		WHERE ` + strings.Join(conditions, " AND ")
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.EntityType, &e.EntityID, &e.Before, &e.After, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
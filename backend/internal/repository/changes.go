@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/stream"
+)
+
+// Changes published to an organization's subscribers once they commit.
+const (
+	ChangeGameRecorded  = "game_recorded"
+	ChangeGameCorrected = "game_corrected"
+	ChangeGameDeleted   = "game_deleted"
+	ChangePlayerCreated = "player_created"
+	ChangeRankChanged   = "rank_changed"
+	ChangeLeaderChanged = "leader_changed"
+)
+
+func changesTopic(orgID int) string {
+	return fmt.Sprintf("organizations/%d", orgID)
+}
+
+// SubscribeChanges returns the changes committed from now on in the
+// organization ctx is scoped to, a commit at a time, and a function that ends
+// the subscription. Each message lists the players it concerns. Only changes
+// made through this process are seen, and the channel is closed if the
+// subscriber falls behind.
+func (r *Repository) SubscribeChanges(ctx context.Context) (<-chan []stream.Message, func()) {
+	return r.changes.Subscribe(changesTopic(organizationID(ctx)))
+}
+
+// changeSet collects what a transaction changes so it can be published
+// once, and only if, the transaction commits.
+type changeSet struct {
+	standings map[int]models.RankChange
+	messages  []stream.Message
+	gameIDs   []int
+}
+
+// trackChanges starts a change set for a transaction that may move players
+// on the leaderboard, noting where everyone stands before it does. It must
+// come after the transaction takes the ledger lock, or a writer committing
+// in between would be missed from the standings and its moves published
+// twice.
+func trackChanges(ctx context.Context, q querier) (*changeSet, error) {
+	standings, err := leaderboardRanks(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	byPlayer := make(map[int]models.RankChange, len(standings))
+	for _, s := range standings {
+		byPlayer[s.PlayerID] = s
+	}
+	return &changeSet{standings: byPlayer}, nil
+}
+
+func (c *changeSet) add(change string, v interface{}, playerIDs ...int) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.messages = append(c.messages, stream.Message{Event: change, Data: data, Players: playerIDs})
+	return nil
+}
+
+func (c *changeSet) addGame(change string, game *models.Game) error {
+	ids := make([]int, len(game.Players))
+	for i, gp := range game.Players {
+		ids[i] = gp.PlayerID
+	}
+	c.gameIDs = append(c.gameIDs, game.ID)
+	return c.add(change, game, ids...)
+}
+
+// commit adds a rank change for every player who moved and a leader change
+// if the top of the leaderboard changed hands, queues webhook deliveries,
+// commits tx and then publishes the changes.
+func (r *Repository) commit(ctx context.Context, tx pgx.Tx, changes *changeSet) error {
+	standings, err := leaderboardRanks(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	leaders := models.LeaderChange{Leaders: []models.RankChange{}, PreviousLeaders: []models.RankChange{}}
+	var involved []int
+	for _, s := range standings {
+		if s.Rank == 1 {
+			leaders.Leaders = append(leaders.Leaders, s)
+			involved = append(involved, s.PlayerID)
+		}
+		if before, ok := changes.standings[s.PlayerID]; ok {
+			if before.Rank == s.Rank {
+				continue
+			}
+			s.PreviousRank = &before.Rank
+		}
+		if err := changes.add(ChangeRankChanged, s, s.PlayerID); err != nil {
+			return err
+		}
+	}
+	for _, s := range changes.standings {
+		if s.Rank == 1 {
+			leaders.PreviousLeaders = append(leaders.PreviousLeaders, s)
+			involved = append(involved, s.PlayerID)
+		}
+	}
+	if leadersChanged(leaders) {
+		sort.Slice(leaders.PreviousLeaders, func(i, j int) bool {
+			return leaders.PreviousLeaders[i].PlayerID < leaders.PreviousLeaders[j].PlayerID
+		})
+		if err := changes.add(ChangeLeaderChanged, leaders, involved...); err != nil {
+			return err
+		}
+	}
+
+	if err := enqueueWebhooks(ctx, tx, changes.messages); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	events := make([]string, len(changes.messages))
+	for i, msg := range changes.messages {
+		events[i] = msg.Event
+	}
+	if len(changes.messages) > 0 {
+		r.changes.Publish(changesTopic(organizationID(ctx)), changes.messages...)
+	}
+	slog.InfoContext(ctx, "Changes committed", "changes", events, "game_ids", changes.gameIDs)
+	return nil
+}
+
+// leadersChanged reports whether the players sharing first place differ
+// from those who shared it before.
+func leadersChanged(c models.LeaderChange) bool {
+	if len(c.Leaders) != len(c.PreviousLeaders) {
+		return true
+	}
+	previous := make(map[int]bool, len(c.PreviousLeaders))
+	for _, s := range c.PreviousLeaders {
+		previous[s.PlayerID] = true
+	}
+	for _, s := range c.Leaders {
+		if !previous[s.PlayerID] {
+			return true
+		}
+	}
+	return false
+}
+
+// leaderboardRanks ranks the organization's players by rating, with tied
+// players sharing a rank.
+func leaderboardRanks(ctx context.Context, q querier) ([]models.RankChange, error) {
+	rows, err := q.Query(ctx,
+		`SELECT id, name, rating, RANK() OVER (ORDER BY rating DESC) FROM players WHERE organization_id = $1 ORDER BY 4, id`,
+		organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var standings []models.RankChange
+	for rows.Next() {
+		var s models.RankChange
+		if err := rows.Scan(&s.PlayerID, &s.PlayerName, &s.Rating, &s.Rank); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+	return standings, rows.Err()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var ErrChatHandleNotFound = errors.New("chat handle not found")
+
+func scanChatHandle(row pgx.Row) (*models.ChatHandle, error) {
+	var h models.ChatHandle
+	err := row.Scan(&h.ID, &h.Handle, &h.PlayerID, &h.PlayerName, &h.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrChatHandleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// NormalizeChatHandle is the form handles are stored and looked up in:
+// trimmed, without a leading @ and lowercased.
+func NormalizeChatHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+func (r *Repository) ListChatHandles(ctx context.Context) ([]models.ChatHandle, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT h.id, h.handle, h.player_id, p.name, h.created_at
+		 FROM chat_handles h
+		 JOIN players p ON p.id = h.player_id
+		 WHERE h.organization_id = $1
+		 ORDER BY h.handle`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	handles := []models.ChatHandle{}
+	for rows.Next() {
+		h, err := scanChatHandle(rows)
+		if err != nil {
+			return nil, err
+		}
+		handles = append(handles, *h)
+	}
+	return handles, rows.Err()
+}
+
+// SetChatHandle links handle to a player, replacing whoever it was linked
+// to before. It returns ErrChatHandleNotFound if the player does not exist.
+func (r *Repository) SetChatHandle(ctx context.Context, handle string, playerID int) (*models.ChatHandle, error) {
+	return scanChatHandle(r.db.QueryRow(ctx,
+		`WITH player AS (
+			SELECT id, name FROM players WHERE id = $3 AND organization_id = $1
+		), linked AS (
+			INSERT INTO chat_handles (organization_id, handle, player_id)
+			SELECT $1, $2, id FROM player
+			ON CONFLICT (organization_id, handle)
+			DO UPDATE SET player_id = EXCLUDED.player_id, created_at = NOW()
+			RETURNING id, handle, player_id, created_at
+		)
+		SELECT linked.id, linked.handle, linked.player_id, player.name, linked.created_at
+		FROM linked, player`,
+		organizationID(ctx), NormalizeChatHandle(handle), playerID))
+}
+
+func (r *Repository) DeleteChatHandle(ctx context.Context, handle string) error {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM chat_handles WHERE organization_id = $1 AND handle = $2`,
+		organizationID(ctx), NormalizeChatHandle(handle))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrChatHandleNotFound
+	}
+	return nil
+}
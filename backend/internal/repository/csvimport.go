@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/csvimport"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// ImportHistoricalGames records games from a spreadsheet with their original
+// dates, creating players that do not exist yet, then replays the ledger so
+// every rating is computed in date order. Players are matched to existing
+// ones by case-insensitive name, and game types the organization has not
+// enabled are rejected. Nothing is written if any row has a problem
+// or when dryRun is set; report collects the problems either way.
+func (r *Repository) ImportHistoricalGames(ctx context.Context, rows []csvimport.Row, dryRun bool, report *csvimport.Report) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockLedger(ctx, tx); err != nil {
+		return err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := playerIDsByName(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	var formats []string
+	if org, ok := OrganizationFromContext(ctx); ok {
+		formats = org.GameFormats
+	}
+
+	// New players are created as of their first imported game so
+	// point-in-time queries see them from then on.
+	firstSeen := make(map[string]time.Time)
+	var newNames []string
+	for _, row := range rows {
+		if !slices.Contains(formats, row.GameType) {
+			report.Errors = append(report.Errors, csvimport.RowError{
+				Line:    row.Line,
+				Message: fmt.Sprintf("game type %q is not enabled for this organization", row.GameType),
+			})
+		}
+		for _, team := range row.Teams {
+			for _, name := range team.Players {
+				key := strings.ToLower(name)
+				switch ids := existing[key]; {
+				case len(ids) > 1:
+					report.Errors = append(report.Errors, csvimport.RowError{
+						Line:    row.Line,
+						Message: fmt.Sprintf("player name %q matches %d players", name, len(ids)),
+					})
+				case len(ids) == 0:
+					if first, ok := firstSeen[key]; !ok {
+						newNames = append(newNames, name)
+						firstSeen[key] = row.PlayedAt
+					} else if row.PlayedAt.Before(first) {
+						firstSeen[key] = row.PlayedAt
+					}
+				}
+			}
+		}
+	}
+	report.PlayersCreated = newNames
+
+	if dryRun || len(report.Errors) > 0 {
+		return nil
+	}
+
+	for _, name := range newNames {
+		createdAt := firstSeen[strings.ToLower(name)]
+		if now := time.Now().UTC(); createdAt.After(now) {
+			createdAt = now
+		}
+
+		var player models.Player
+		err := tx.QueryRow(ctx,
+			`INSERT INTO players (organization_id, name, rating, created_at) VALUES ($1, $2, $3, $4) RETURNING id, name, rating, games_played, version, created_at`,
+			organizationID(ctx), name, elo.InitialRating, createdAt,
+		).Scan(&player.ID, &player.Name, &player.Rating, &player.GamesPlayed, &player.Version, &player.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		created := ledger.PlayerCreatedPayload{PlayerID: player.ID, Name: player.Name, CreatedAt: player.CreatedAt}
+		if err := appendEvent(ctx, tx, ledger.PlayerCreated, player.ID, created); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditCreate, AuditEntityPlayer, player.ID, nil, &player); err != nil {
+			return err
+		}
+		if err := changes.add(ChangePlayerCreated, &player, player.ID); err != nil {
+			return err
+		}
+		existing[strings.ToLower(name)] = []int{player.ID}
+	}
+
+	gameIDs := make([]int, 0, len(rows))
+	for _, row := range rows {
+		var gameID int
+		var createdAt time.Time
+		err := tx.QueryRow(ctx,
+			`INSERT INTO games (organization_id, game_type, played_at) VALUES ($1, $2, $3) RETURNING id, created_at`,
+			organizationID(ctx), row.GameType, row.PlayedAt,
+		).Scan(&gameID, &createdAt)
+		if err != nil {
+			return err
+		}
+
+		teams := make([]models.CreateGameTeam, len(row.Teams))
+		for i, team := range row.Teams {
+			teams[i].Score = team.Score
+			for _, name := range team.Players {
+				teams[i].PlayerIDs = append(teams[i].PlayerIDs, existing[strings.ToLower(name)][0])
+			}
+		}
+
+		playedAt := row.PlayedAt
+		recorded := ledger.GameRecordedPayload{GameID: gameID, GameType: row.GameType, Teams: teams, PlayedAt: &playedAt, CreatedAt: createdAt}
+		if err := appendEvent(ctx, tx, ledger.GameRecorded, gameID, recorded); err != nil {
+			return err
+		}
+		gameIDs = append(gameIDs, gameID)
+	}
+
+	// Participants and ratings for the new games, and every game played
+	// after them, come from the replay.
+	if err := rebuildProjections(ctx, tx); err != nil {
+		return err
+	}
+
+	for _, id := range gameIDs {
+		game, err := getGame(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditCreate, AuditEntityGame, id, nil, game); err != nil {
+			return err
+		}
+		if err := changes.addGame(ChangeGameRecorded, game); err != nil {
+			return err
+		}
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return err
+	}
+	report.GamesImported = len(gameIDs)
+	return nil
+}
+
+func playerIDsByName(ctx context.Context, q querier) (map[string][]int, error) {
+	rows, err := q.Query(ctx, `SELECT id, LOWER(name) FROM players WHERE organization_id = $1 ORDER BY id`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byName := make(map[string][]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		byName[name] = append(byName[name], id)
+	}
+	return byName, rows.Err()
+}
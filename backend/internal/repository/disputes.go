@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var (
+	ErrDisputeNotFound = errors.New("dispute not found")
+	ErrDisputeOpen     = errors.New("game already has an open dispute")
+	ErrDisputeResolved = errors.New("dispute is already resolved")
+)
+
+// Statuses of a dispute: open until an admin resolves it one of the other
+// ways.
+const (
+	DisputeOpen      = "open"
+	DisputeUpheld    = "upheld"
+	DisputeCorrected = "corrected"
+	DisputeVoided    = "voided"
+)
+
+const disputeColumns = `id, game_id, comment, opened_by, status, resolution_comment, resolved_by, resolved_at, created_at`
+
+// disputedColumn reports whether the game whose ID is gameRef has an open
+// dispute.
+func disputedColumn(gameRef string) string {
+	return `EXISTS (SELECT 1 FROM game_disputes d WHERE d.game_id = ` + gameRef + ` AND d.status = 'open')`
+}
+
+func scanDispute(row pgx.Row) (*models.Dispute, error) {
+	var d models.Dispute
+	err := row.Scan(&d.ID, &d.GameID, &d.Comment, &d.OpenedBy, &d.Status, &d.ResolutionComment, &d.ResolvedBy, &d.ResolvedAt, &d.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// closeDisputes resolves the open dispute about a game, if there is one, as
+// voided along with the game.
+func closeDisputes(ctx context.Context, tx pgx.Tx, gameID int) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE game_disputes SET status = $3, resolved_by = $4, resolved_at = NOW()
+		 WHERE game_id = $1 AND organization_id = $2 AND status = 'open'`,
+		gameID, organizationID(ctx), DisputeVoided, actorFromContext(ctx))
+	return err
+}
+
+// OpenDispute challenges a game's result, flagging it as disputed until an
+// admin resolves the dispute.
+func (r *Repository) OpenDispute(ctx context.Context, gameID int, comment string) (*models.Dispute, error) {
+	d, err := scanDispute(r.db.QueryRow(ctx,
+		`INSERT INTO game_disputes (organization_id, game_id, comment, opened_by)
+		 SELECT organization_id, id, $3, $4 FROM games WHERE id = $1 AND organization_id = $2
+		 RETURNING `+disputeColumns,
+		gameID, organizationID(ctx), comment, actorFromContext(ctx)))
+	if isUniqueViolation(err) {
+		return nil, ErrDisputeOpen
+	}
+	return d, err
+}
+
+// ListDisputes returns the organization's disputes with status, or of any
+// status if it is empty, optionally only those about one game. Newest come
+// first.
+func (r *Repository) ListDisputes(ctx context.Context, gameID *int, status string, limit int) ([]models.Dispute, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+disputeColumns+` FROM game_disputes
+		 WHERE organization_id = $1 AND ($2::int IS NULL OR game_id = $2) AND ($3 = '' OR status = $3)
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT $4`,
+		organizationID(ctx), gameID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disputes := []models.Dispute{}
+	for rows.Next() {
+		d, err := scanDispute(rows)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, *d)
+	}
+	return disputes, rows.Err()
+}
+
+func (r *Repository) GetDispute(ctx context.Context, disputeID int) (*models.Dispute, error) {
+	return scanDispute(r.db.QueryRow(ctx,
+		`SELECT `+disputeColumns+` FROM game_disputes WHERE id = $1 AND organization_id = $2`, disputeID, organizationID(ctx)))
+}
+
+// ResolveDispute closes an open dispute. Correcting or voiding the game
+// happens in the same transaction, replaying the ledger so every later
+// rating is recomputed, and is audited like any other correction.
+func (r *Repository) ResolveDispute(ctx context.Context, disputeID int, req models.ResolveDisputeRequest) (*models.Dispute, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := scanDispute(tx.QueryRow(ctx,
+		`SELECT `+disputeColumns+` FROM game_disputes WHERE id = $1 AND organization_id = $2 FOR UPDATE`,
+		disputeID, organizationID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != DisputeOpen {
+		return nil, ErrDisputeResolved
+	}
+
+	switch req.Resolution {
+	case DisputeUpheld:
+	case DisputeCorrected:
+		var team1Score, team2Score int
+		if req.Team1Score != nil && req.Team2Score != nil {
+			team1Score, team2Score = *req.Team1Score, *req.Team2Score
+		} else if len(req.Goals) == 0 {
+			return nil, fmt.Errorf("%w: a correction needs a score or goals", ledger.ErrInvalidGoals)
+		}
+		if _, err := correctGame(ctx, tx, changes, before.GameID, team1Score, team2Score, req.Goals, nil); err != nil {
+			return nil, err
+		}
+	case DisputeVoided:
+		if err := voidGame(ctx, tx, changes, before.GameID, nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown resolution %q", req.Resolution)
+	}
+
+	var comment *string
+	if req.Comment != "" {
+		comment = &req.Comment
+	}
+	after, err := scanDispute(tx.QueryRow(ctx,
+		`UPDATE game_disputes SET status = $2, resolution_comment = $3, resolved_by = $4, resolved_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+disputeColumns,
+		before.ID, req.Resolution, comment, actorFromContext(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
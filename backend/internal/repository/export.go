@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/export"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var (
+	ErrOrganizationNotEmpty = errors.New("organization is not empty")
+	ErrImportIDConflict     = errors.New("imported IDs are already in use")
+	ErrImportRatingSettings = errors.New("the export was rated with different rating settings")
+	ErrImportReplayMismatch = errors.New("the imported ratings do not match a replay of the ledger")
+)
+
+// Export reads every table, player, game and ledger event of the
+// organization from a single snapshot and hands them to sink in that order.
+func (r *Repository) Export(ctx context.Context, sink export.Sink) error {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := exportTables(ctx, tx, sink); err != nil {
+		return err
+	}
+	if err := exportPlayers(ctx, tx, sink); err != nil {
+		return err
+	}
+	if err := exportGames(ctx, tx, sink); err != nil {
+		return err
+	}
+	if err := exportEvents(ctx, tx, sink); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func exportTables(ctx context.Context, tx pgx.Tx, sink export.Sink) error {
+	rows, err := tx.Query(ctx, `SELECT `+tableColumns+` FROM tables WHERE organization_id = $1 ORDER BY id`, organizationID(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTable(rows)
+		if err != nil {
+			return err
+		}
+		if err := sink.Table(*t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func exportPlayers(ctx context.Context, tx pgx.Tx, sink export.Sink) error {
+	rows, err := tx.Query(ctx,
+		`SELECT id, name, rating, games_played, version, created_at FROM players WHERE organization_id = $1 ORDER BY id`,
+		organizationID(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Player
+		if err := rows.Scan(&p.ID, &p.Name, &p.Rating, &p.GamesPlayed, &p.Version, &p.CreatedAt); err != nil {
+			return err
+		}
+		if err := sink.Player(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func exportGames(ctx context.Context, tx pgx.Tx, sink export.Sink) error {
+	rows, err := tx.Query(ctx,
+		`SELECT g.id, g.game_type, g.table_id, g.version, g.played_at, g.created_at, `+goalsColumn("g.id")+`, gp.player_id, p.name, gp.team, COALESCE(gp.side, ''), gp.score, gp.rating_before, gp.rating_after
+		 FROM games g
+		 LEFT JOIN game_participants gp ON g.id = gp.game_id
+		 LEFT JOIN players p ON gp.player_id = p.id
+		 WHERE g.organization_id = $1
+		 ORDER BY g.id, gp.team, gp.player_id`, organizationID(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *models.Game
+	for rows.Next() {
+		var game models.Game
+		var playerID, team, score, ratingBefore, ratingAfter *int
+		var playerName, side *string
+		var goals []byte
+		if err := rows.Scan(&game.ID, &game.GameType, &game.TableID, &game.Version, &game.PlayedAt, &game.CreatedAt, &goals, &playerID, &playerName, &team, &side, &score, &ratingBefore, &ratingAfter); err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != game.ID {
+			if current != nil {
+				if err := sink.Game(*current); err != nil {
+					return err
+				}
+			}
+			game.Players = []models.GamePlayer{}
+			var err error
+			if game.Goals, err = decodeGoals(goals); err != nil {
+				return err
+			}
+			current = &game
+		}
+		if playerID != nil {
+			current.Players = append(current.Players, models.GamePlayer{
+				PlayerID:     *playerID,
+				PlayerName:   *playerName,
+				Team:         *team,
+				Side:         *side,
+				Score:        *score,
+				RatingBefore: *ratingBefore,
+				RatingAfter:  *ratingAfter,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return sink.Game(*current)
+	}
+	return nil
+}
+
+func exportEvents(ctx context.Context, tx pgx.Tx, sink export.Sink) error {
+	events, err := loadEvents(ctx, tx, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := sink.Event(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import restores a validated export into an empty organization, keeping
+// table, player and game IDs, versions and timestamps, and moves the ID sequences
+// past the imported rows. IDs are shared by all organizations, so an export
+// cannot be imported while its IDs are still in use elsewhere. Events are
+// renumbered in their original order. The ledger is then replayed with the
+// organization's rating settings, and the import is refused unless that
+// reproduces the imported players and games exactly; otherwise the next
+// replay would quietly rewrite them.
+func (r *Repository) Import(ctx context.Context, doc *export.Document) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockLedger(ctx, tx); err != nil {
+		return err
+	}
+
+	if doc.RatingSystem != "" {
+		calc, err := calculator(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if calc != doc.Calculator() {
+			return ErrImportRatingSettings
+		}
+	}
+
+	orgID := organizationID(ctx)
+	var hasData bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM tables WHERE organization_id = $1)
+		     OR EXISTS (SELECT 1 FROM players WHERE organization_id = $1)
+		     OR EXISTS (SELECT 1 FROM games WHERE organization_id = $1)
+		     OR EXISTS (SELECT 1 FROM events WHERE organization_id = $1)`,
+		orgID,
+	).Scan(&hasData)
+	if err != nil {
+		return err
+	}
+	if hasData {
+		return ErrOrganizationNotEmpty
+	}
+
+	tableIDs := make([]int, len(doc.Tables))
+	for i, t := range doc.Tables {
+		tableIDs[i] = t.ID
+	}
+	playerIDs := make([]int, len(doc.Players))
+	for i, p := range doc.Players {
+		playerIDs[i] = p.ID
+	}
+	gameIDs := make([]int, len(doc.Games))
+	for i, g := range doc.Games {
+		gameIDs[i] = g.ID
+	}
+	var conflict bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM tables WHERE id = ANY($1))
+		     OR EXISTS (SELECT 1 FROM players WHERE id = ANY($2))
+		     OR EXISTS (SELECT 1 FROM games WHERE id = ANY($3))`,
+		tableIDs, playerIDs, gameIDs,
+	).Scan(&conflict)
+	if err != nil {
+		return err
+	}
+	if conflict {
+		return ErrImportIDConflict
+	}
+
+	tables := make([][]interface{}, len(doc.Tables))
+	for i, t := range doc.Tables {
+		tables[i] = []interface{}{t.ID, orgID, t.Name, t.Location, t.KMultiplier, t.Version, t.CreatedAt}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"tables"},
+		[]string{"id", "organization_id", "name", "location", "k_multiplier", "version", "created_at"},
+		pgx.CopyFromRows(tables))
+	if err != nil {
+		return err
+	}
+
+	players := make([][]interface{}, len(doc.Players))
+	for i, p := range doc.Players {
+		players[i] = []interface{}{p.ID, orgID, p.Name, p.Rating, p.GamesPlayed, p.Version, p.CreatedAt}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"players"},
+		[]string{"id", "organization_id", "name", "rating", "games_played", "version", "created_at"},
+		pgx.CopyFromRows(players))
+	if err != nil {
+		return err
+	}
+
+	games := make([][]interface{}, len(doc.Games))
+	var participants, goals [][]interface{}
+	for i, g := range doc.Games {
+		games[i] = []interface{}{g.ID, orgID, g.GameType, g.TableID, g.Version, g.PlayedAt, g.CreatedAt}
+		for _, gp := range g.Players {
+			participants = append(participants, []interface{}{g.ID, gp.PlayerID, gp.Team, sideValue(gp.Side), gp.Score, gp.RatingBefore, gp.RatingAfter, g.CreatedAt})
+		}
+		goals = append(goals, goalRows(g.ID, g.Goals)...)
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"games"},
+		[]string{"id", "organization_id", "game_type", "table_id", "version", "played_at", "created_at"},
+		pgx.CopyFromRows(games))
+	if err != nil {
+		return err
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"game_participants"},
+		[]string{"game_id", "player_id", "team", "side", "score", "rating_before", "rating_after", "created_at"},
+		pgx.CopyFromRows(participants))
+	if err != nil {
+		return err
+	}
+	if err := copyGoals(ctx, tx, goals); err != nil {
+		return err
+	}
+
+	events := make([][]interface{}, len(doc.Events))
+	for i, e := range doc.Events {
+		events[i] = []interface{}{orgID, e.Type, e.AggregateID, []byte(e.Payload), e.Actor, e.CreatedAt}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"events"},
+		[]string{"organization_id", "event_type", "aggregate_id", "payload", "actor", "created_at"},
+		pgx.CopyFromRows(events))
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(`SELECT setval('tables_id_seq', GREATEST((SELECT last_value FROM tables_id_seq), $1))`, slices.Max(append(tableIDs, 1)))
+	batch.Queue(`SELECT setval('players_id_seq', GREATEST((SELECT last_value FROM players_id_seq), $1))`, slices.Max(append(playerIDs, 1)))
+	batch.Queue(`SELECT setval('games_id_seq', GREATEST((SELECT last_value FROM games_id_seq), $1))`, slices.Max(append(gameIDs, 1)))
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	if err := rebuildProjections(ctx, tx); err != nil {
+		return err
+	}
+	if err := checkReplay(ctx, tx, doc); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// checkReplay compares the players and games a replay left behind with the
+// ones in doc.
+func checkReplay(ctx context.Context, tx pgx.Tx, doc *export.Document) error {
+	players, err := playerTotals(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if len(players) != len(doc.Players) {
+		return fmt.Errorf("%w: the ledger has %d players, the export %d", ErrImportReplayMismatch, len(players), len(doc.Players))
+	}
+	for _, p := range doc.Players {
+		got, ok := players[p.ID]
+		if !ok || got.Name != p.Name || got.Rating != p.Rating || got.GamesPlayed != p.GamesPlayed {
+			return fmt.Errorf("%w: player %d", ErrImportReplayMismatch, p.ID)
+		}
+	}
+
+	var games int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM games WHERE organization_id = $1`, organizationID(ctx)).Scan(&games); err != nil {
+		return err
+	}
+	if games != len(doc.Games) {
+		return fmt.Errorf("%w: the ledger has %d games, the export %d", ErrImportReplayMismatch, games, len(doc.Games))
+	}
+	signatures, err := participantSignatures(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, g := range doc.Games {
+		if signatures[g.ID] != gameSignature(g) {
+			return fmt.Errorf("%w: game %d", ErrImportReplayMismatch, g.ID)
+		}
+	}
+	return nil
+}
+
+// playerTotals returns the name, rating and games played of every player in
+// the organization, by ID.
+func playerTotals(ctx context.Context, tx pgx.Tx) (map[int]models.Player, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT id, name, rating, games_played FROM players WHERE organization_id = $1`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := make(map[int]models.Player)
+	for rows.Next() {
+		var p models.Player
+		if err := rows.Scan(&p.ID, &p.Name, &p.Rating, &p.GamesPlayed); err != nil {
+			return nil, err
+		}
+		players[p.ID] = p
+	}
+	return players, rows.Err()
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/export"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// importDocument builds an export of one 10-4 singles game between two new
+// players, rated with calc, and moves the winner's rating by skew.
+func importDocument(t *testing.T, calc elo.Calculator, ratingSystem string, skew int) *export.Document {
+	t.Helper()
+	created := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	winnerDelta, loserDelta := calc.NewRatings(elo.InitialRating, elo.InitialRating, 10, 4)
+	winner := elo.InitialRating + winnerDelta + skew
+	loser := elo.InitialRating + loserDelta
+
+	doc := &export.Document{
+		Format:       export.Format,
+		Version:      export.FormatVersion,
+		RatingSystem: ratingSystem,
+		KFactor:      int(calc.KFactor),
+		Players: []models.Player{
+			{ID: 9001, Name: "Alice", Rating: winner, GamesPlayed: 1, Version: 1, CreatedAt: created},
+			{ID: 9002, Name: "Bob", Rating: loser, GamesPlayed: 1, Version: 1, CreatedAt: created},
+		},
+		Games: []models.Game{{
+			ID:        9101,
+			GameType:  "singles",
+			Version:   1,
+			PlayedAt:  created.Add(time.Hour),
+			CreatedAt: created.Add(time.Hour),
+			Players: []models.GamePlayer{
+				{PlayerID: 9001, Team: 1, Score: 10, RatingBefore: elo.InitialRating, RatingAfter: winner},
+				{PlayerID: 9002, Team: 2, Score: 4, RatingBefore: elo.InitialRating, RatingAfter: loser},
+			},
+		}},
+	}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("validating document: %v", err)
+	}
+	if err := doc.EnsureEvents(); err != nil {
+		t.Fatalf("building ledger: %v", err)
+	}
+	return doc
+}
+
+func TestImportReplaysRatings(t *testing.T) {
+	repo, ctx := newTestRepository(t)
+	margin := elo.Calculator{System: elo.SystemMarginElo, KFactor: elo.KFactor}
+
+	if err := repo.Import(ctx, importDocument(t, margin, elo.SystemMarginElo, 0)); !errors.Is(err, ErrImportRatingSettings) {
+		t.Errorf("importing a margin_elo export into an elo organization = %v, want %v", err, ErrImportRatingSettings)
+	}
+	if err := repo.Import(ctx, importDocument(t, margin, "", 0)); !errors.Is(err, ErrImportReplayMismatch) {
+		t.Errorf("importing margin_elo ratings without settings = %v, want %v", err, ErrImportReplayMismatch)
+	}
+	if err := repo.Import(ctx, importDocument(t, elo.Default, "", 3)); !errors.Is(err, ErrImportReplayMismatch) {
+		t.Errorf("importing ratings the ledger does not produce = %v, want %v", err, ErrImportReplayMismatch)
+	}
+
+	doc := importDocument(t, elo.Default, elo.SystemElo, 0)
+	if err := repo.Import(ctx, doc); err != nil {
+		t.Fatalf("importing a consistent export: %v", err)
+	}
+	got := standings(t, ctx, repo)
+	for _, p := range doc.Players {
+		if got[p.ID].Rating != p.Rating || got[p.ID].GamesPlayed != p.GamesPlayed {
+			t.Errorf("player %d imported as %+v, want rating %d after %d games", p.ID, got[p.ID], p.Rating, p.GamesPlayed)
+		}
+	}
+}
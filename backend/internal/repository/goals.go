@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// comebackDeficit is how many goals behind a team must have been at some
+// point for its win to count as a comeback.
+const comebackDeficit = 3
+
+var goalColumns = []string{"game_id", "seq", "player_id", "team", "rod", "own_goal", "elapsed_seconds"}
+
+// goalsColumn selects the goal timeline of the game whose ID is gameRef as
+// a JSON array, or NULL for games without one, so goals can be read in the
+// same query as the game itself.
+func goalsColumn(gameRef string) string {
+	return `(SELECT json_agg(json_build_object('player_id', e.player_id, 'team', e.team, 'rod', e.rod,
+	        'own_goal', e.own_goal, 'elapsed_seconds', e.elapsed_seconds) ORDER BY e.seq)
+	 FROM game_events e WHERE e.game_id = ` + gameRef + `)`
+}
+
+func decodeGoals(data []byte) ([]models.Goal, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var goals []models.Goal
+	if err := json.Unmarshal(data, &goals); err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+// goalRows lays out a game's goals for copying into game_events.
+func goalRows(gameID int, goals []models.Goal) [][]interface{} {
+	rows := make([][]interface{}, len(goals))
+	for i, g := range goals {
+		rows[i] = []interface{}{gameID, i + 1, g.PlayerID, g.Team, rodValue(g.Rod), g.OwnGoal, g.ElapsedSeconds}
+	}
+	return rows
+}
+
+func copyGoals(ctx context.Context, tx pgx.Tx, rows [][]interface{}) error {
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"game_events"}, goalColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+// rodValue stores a missing rod as NULL.
+func rodValue(rod string) *string {
+	if rod == "" {
+		return nil
+	}
+	return &rod
+}
+
+// goalStats fills in the goal statistics of a player's games that have a
+// goal timeline, optionally only those played on one table.
+func goalStats(ctx context.Context, q querier, playerID int, tableID *int, stats *models.PlayerStats) error {
+	var timedGames int
+	err := q.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE e.player_id = $1 AND NOT e.own_goal),
+			COUNT(*) FILTER (WHERE e.player_id = $1 AND e.own_goal),
+			COUNT(DISTINCT e.game_id)
+		FROM game_participants gp
+		JOIN games g ON gp.game_id = g.id
+		JOIN game_events e ON e.game_id = g.id
+		WHERE gp.player_id = $1 AND g.organization_id = $2 AND ($3::int IS NULL OR g.table_id = $3)`,
+		playerID, organizationID(ctx), tableID).
+		Scan(&stats.GoalsScored, &stats.OwnGoals, &timedGames)
+	if err != nil {
+		return err
+	}
+	if timedGames > 0 {
+		stats.GoalsPerGame = float64(stats.GoalsScored) / float64(timedGames)
+	}
+
+	// Replay each timeline from the player's side: a comeback is a win from
+	// comebackDeficit or more goals down.
+	return q.QueryRow(ctx, `
+		WITH timeline AS (
+			SELECT e.game_id,
+			       CASE WHEN e.team = gp.team THEN 1 ELSE -1 END AS swing,
+			       SUM(CASE WHEN e.team = gp.team THEN 1 ELSE -1 END) OVER (PARTITION BY e.game_id ORDER BY e.seq) AS lead
+			FROM game_participants gp
+			JOIN games g ON gp.game_id = g.id
+			JOIN game_events e ON e.game_id = g.id
+			WHERE gp.player_id = $1 AND g.organization_id = $2 AND ($3::int IS NULL OR g.table_id = $3)
+		)
+		SELECT COUNT(*) FROM (
+			SELECT game_id FROM timeline
+			GROUP BY game_id
+			HAVING SUM(swing) > 0 AND MIN(lead) <= -$4::int
+		) comebacks`,
+		playerID, organizationID(ctx), tableID, comebackDeficit).
+		Scan(&stats.Comebacks)
+}
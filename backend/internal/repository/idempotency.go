@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// idempotencyLease is how long a reservation holds its key while the request
+// is processed. Requests finish well within it, so a reservation still
+// unfinished once it has passed was left behind by a process that died, and a
+// retry may take it over.
+const idempotencyLease = time.Minute
+
+// ReserveIdempotencyKey claims key for a request. It returns nil when the key
+// is new, or its earlier request was abandoned, and the caller should process
+// the request, or the existing record when the key has been seen before and
+// has not expired.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, key models.IdempotencyKey, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	if _, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`); err != nil {
+		return nil, err
+	}
+
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO idempotency_keys (organization_id, caller, key, method, path, request_hash, expires_at, locked_until)
+		 VALUES ($1, $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second', NOW() + $8 * INTERVAL '1 second')
+		 ON CONFLICT (organization_id, caller, key, method, path) DO UPDATE
+		 SET locked_until = EXCLUDED.locked_until
+		 WHERE idempotency_keys.status_code IS NULL
+		   AND idempotency_keys.locked_until < NOW()
+		   AND idempotency_keys.request_hash = EXCLUDED.request_hash`,
+		organizationID(ctx), key.Caller, key.Key, key.Method, key.Route, requestHash, ttl.Seconds(), idempotencyLease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var rec models.IdempotencyRecord
+	err = r.db.QueryRow(ctx,
+		`SELECT request_hash, status_code, response_body FROM idempotency_keys
+		 WHERE organization_id = $1 AND caller = $2 AND key = $3 AND method = $4 AND path = $5`,
+		organizationID(ctx), key.Caller, key.Key, key.Method, key.Route,
+	).Scan(&rec.RequestHash, &rec.StatusCode, &rec.ResponseBody)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, key models.IdempotencyKey, statusCode int, body []byte) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE idempotency_keys SET status_code = $6, response_body = $7
+		 WHERE organization_id = $1 AND caller = $2 AND key = $3 AND method = $4 AND path = $5`,
+		organizationID(ctx), key.Caller, key.Key, key.Method, key.Route, statusCode, body,
+	)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reservation whose request failed so the
+// client can retry with the same key.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, key models.IdempotencyKey) error {
+	_, err := r.db.Exec(ctx,
+		`DELETE FROM idempotency_keys
+		 WHERE organization_id = $1 AND caller = $2 AND key = $3 AND method = $4 AND path = $5 AND status_code IS NULL`,
+		organizationID(ctx), key.Caller, key.Key, key.Method, key.Route,
+	)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	repo, ctx := newTestRepository(t)
+	alice := models.IdempotencyKey{Caller: "api-key:1", Key: "retry-me", Method: "POST", Route: "/api/games"}
+	bob := alice
+	bob.Caller = "api-key:2"
+
+	reserve := func(key models.IdempotencyKey, hash string) *models.IdempotencyRecord {
+		t.Helper()
+		rec, err := repo.ReserveIdempotencyKey(ctx, key, hash, time.Hour)
+		if err != nil {
+			t.Fatalf("reserving key: %v", err)
+		}
+		return rec
+	}
+
+	if rec := reserve(alice, "a"); rec != nil {
+		t.Fatalf("new key returned %+v, want a reservation", rec)
+	}
+	if rec := reserve(bob, "b"); rec != nil {
+		t.Errorf("another caller's key returned %+v, want a reservation of its own", rec)
+	}
+	if rec := reserve(alice, "a"); rec == nil || rec.StatusCode != nil {
+		t.Errorf("repeat while in progress returned %+v, want the unfinished reservation", rec)
+	}
+
+	// A reservation left unfinished past its lease was abandoned, and only
+	// a retry of the same request may take it over.
+	if _, err := repo.db.Exec(ctx, `UPDATE idempotency_keys SET locked_until = NOW() - INTERVAL '1 second'`); err != nil {
+		t.Fatalf("expiring lease: %v", err)
+	}
+	if rec := reserve(alice, "different"); rec == nil {
+		t.Error("a different request took over an abandoned reservation")
+	}
+	if rec := reserve(alice, "a"); rec != nil {
+		t.Errorf("retry after the lease returned %+v, want to take the reservation over", rec)
+	}
+	if rec := reserve(alice, "a"); rec == nil || rec.StatusCode != nil {
+		t.Errorf("repeat after the takeover returned %+v, want the unfinished reservation", rec)
+	}
+
+	if err := repo.CompleteIdempotencyKey(ctx, alice, 201, []byte(`{}`)); err != nil {
+		t.Fatalf("completing key: %v", err)
+	}
+	if rec := reserve(alice, "a"); rec == nil || rec.StatusCode == nil || *rec.StatusCode != 201 {
+		t.Errorf("repeat after completion returned %+v, want the stored 201", rec)
+	}
+	if rec := reserve(bob, "b"); rec == nil || rec.StatusCode != nil {
+		t.Errorf("another caller's key returned %+v, want its own unfinished reservation", rec)
+	}
+}
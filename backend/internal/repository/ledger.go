@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+func appendEvent(ctx context.Context, tx pgx.Tx, eventType string, aggregateID int, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO events (organization_id, event_type, aggregate_id, payload, actor) VALUES ($1, $2, $3, $4, $5)`,
+		organizationID(ctx), eventType, aggregateID, data, actorFromContext(ctx),
+	)
+	return err
+}
+
+// lockLedger blocks other writers from appending events until tx finishes so
+// a rebuild sees a stable history. It must be taken before appending any
+// event in the same transaction to avoid lock upgrades.
+func lockLedger(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `LOCK TABLE events IN SHARE ROW EXCLUSIVE MODE`)
+	return err
+}
+
+// joinLedger takes the lock every event writer shares, before any other row
+// is touched. Taking it up front keeps lock order consistent with rebuilds,
+// which hold the ledger lock and then rewrite player rows.
+func joinLedger(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `LOCK TABLE events IN ROW EXCLUSIVE MODE`)
+	return err
+}
+
+func loadEvents(ctx context.Context, q querier, afterID int64, limit int) ([]ledger.Event, error) {
+	query := `SELECT id, event_type, aggregate_id, payload, actor, created_at FROM events WHERE organization_id = $1 AND id > $2 ORDER BY id`
+	args := []interface{}{organizationID(ctx), afterID}
+	if limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, limit)
+	}
+
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []ledger.Event{}
+	for rows.Next() {
+		var e ledger.Event
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &e.Payload, &e.Actor, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *Repository) ListEvents(ctx context.Context, afterID int64, limit int) ([]ledger.Event, error) {
+	return loadEvents(ctx, r.db, afterID, limit)
+}
+
+// RebuildProjections replays the organization's ledger and rewrites its
+// players, games, game_participants and game_events from it.
+func (r *Repository) RebuildProjections(ctx context.Context) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockLedger(ctx, tx); err != nil {
+		return err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := rebuildProjections(ctx, tx); err != nil {
+		return err
+	}
+
+	return r.commit(ctx, tx, changes)
+}
+
+func rebuildProjections(ctx context.Context, tx pgx.Tx) error {
+	events, err := loadEvents(ctx, tx, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	calc, err := calculator(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	multipliers, err := tableMultipliers(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	proj, err := ledger.Project(events, calc, multipliers)
+	if err != nil {
+		return err
+	}
+
+	existing, err := participantSignatures(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	orgID := organizationID(ctx)
+	playerIDs := make([]int, len(proj.Players))
+	gameIDs := make([]int, len(proj.Games))
+	var changedGameIDs []int
+
+	batch := &pgx.Batch{}
+	for i, p := range proj.Players {
+		playerIDs[i] = p.ID
+		batch.Queue(
+			`INSERT INTO players (id, organization_id, name, rating, games_played, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, rating = EXCLUDED.rating, games_played = EXCLUDED.games_played,
+			     version = players.version + 1
+			 WHERE players.organization_id = EXCLUDED.organization_id
+			   AND (players.name, players.rating, players.games_played) IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.rating, EXCLUDED.games_played)`,
+			p.ID, orgID, p.Name, p.Rating, p.GamesPlayed, p.CreatedAt,
+		)
+	}
+	for i, g := range proj.Games {
+		gameIDs[i] = g.ID
+		if sig, ok := existing[g.ID]; ok && sig != gameSignature(g) {
+			changedGameIDs = append(changedGameIDs, g.ID)
+		}
+		batch.Queue(
+			`INSERT INTO games (id, organization_id, game_type, table_id, played_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (id) DO UPDATE SET game_type = EXCLUDED.game_type, table_id = EXCLUDED.table_id, played_at = EXCLUDED.played_at
+			 WHERE games.organization_id = EXCLUDED.organization_id`,
+			g.ID, orgID, g.GameType, g.TableID, g.PlayedAt, g.CreatedAt,
+		)
+	}
+	batch.Queue(`DELETE FROM players WHERE organization_id = $1 AND NOT (id = ANY($2))`, orgID, playerIDs)
+	batch.Queue(`DELETE FROM games WHERE organization_id = $1 AND NOT (id = ANY($2))`, orgID, gameIDs)
+	batch.Queue(`UPDATE games SET version = version + 1 WHERE organization_id = $1 AND id = ANY($2)`, orgID, changedGameIDs)
+	batch.Queue(`DELETE FROM game_participants WHERE game_id IN (SELECT id FROM games WHERE organization_id = $1)`, orgID)
+	batch.Queue(`DELETE FROM game_events WHERE game_id IN (SELECT id FROM games WHERE organization_id = $1)`, orgID)
+	batch.Queue(`SELECT setval('players_id_seq', GREATEST((SELECT last_value FROM players_id_seq), $1))`, proj.MaxPlayerID)
+	batch.Queue(`SELECT setval('games_id_seq', GREATEST((SELECT last_value FROM games_id_seq), $1))`, proj.MaxGameID)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	var participants, goals [][]interface{}
+	for _, g := range proj.Games {
+		for _, gp := range g.Players {
+			participants = append(participants, []interface{}{g.ID, gp.PlayerID, gp.Team, sideValue(gp.Side), gp.Score, gp.RatingBefore, gp.RatingAfter, g.CreatedAt})
+		}
+		goals = append(goals, goalRows(g.ID, g.Goals)...)
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"game_participants"},
+		[]string{"game_id", "player_id", "team", "side", "score", "rating_before", "rating_after", "created_at"},
+		pgx.CopyFromRows(participants),
+	)
+	if err != nil {
+		return err
+	}
+	return copyGoals(ctx, tx, goals)
+}
+
+// participantSignatures summarises each stored game's participants so a
+// rebuild can tell which games it actually changed and bump only their
+// versions.
+func participantSignatures(ctx context.Context, tx pgx.Tx) (map[int]string, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT gp.game_id, string_agg(gp.player_id || ':' || gp.team || ':' || gp.score || ':' || gp.rating_before || ':' || gp.rating_after, ',' ORDER BY gp.team, gp.player_id)
+		 FROM game_participants gp
+		 JOIN games g ON gp.game_id = g.id
+		 WHERE g.organization_id = $1
+		 GROUP BY gp.game_id`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	signatures := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var sig string
+		if err := rows.Scan(&id, &sig); err != nil {
+			return nil, err
+		}
+		signatures[id] = sig
+	}
+	return signatures, rows.Err()
+}
+
+func gameSignature(g models.Game) string {
+	players := append([]models.GamePlayer(nil), g.Players...)
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Team != players[j].Team {
+			return players[i].Team < players[j].Team
+		}
+		return players[i].PlayerID < players[j].PlayerID
+	})
+
+	parts := make([]string, len(players))
+	for i, gp := range players {
+		parts[i] = fmt.Sprintf("%d:%d:%d:%d:%d", gp.PlayerID, gp.Team, gp.Score, gp.RatingBefore, gp.RatingAfter)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var ErrLiveGameNotFound = errors.New("live game not found")
+
+const liveGameColumns = `id, game_type, table_id, teams, goals, version, started_at`
+
+// scanLiveGame reads a live game and works out its score from its goals.
+func scanLiveGame(row pgx.Row) (*models.LiveGame, error) {
+	var g models.LiveGame
+	err := row.Scan(&g.ID, &g.GameType, &g.TableID, &g.Teams, &g.Goals, &g.Version, &g.StartedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLiveGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ledger.ScoreGoals(g.Teams, g.Goals); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *Repository) ListLiveGames(ctx context.Context) ([]models.LiveGame, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+liveGameColumns+` FROM live_games WHERE organization_id = $1 ORDER BY started_at, id`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []models.LiveGame{}
+	for rows.Next() {
+		g, err := scanLiveGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, *g)
+	}
+	return games, rows.Err()
+}
+
+func (r *Repository) GetLiveGame(ctx context.Context, liveGameID int) (*models.LiveGame, error) {
+	return scanLiveGame(r.db.QueryRow(ctx,
+		`SELECT `+liveGameColumns+` FROM live_games WHERE id = $1 AND organization_id = $2`, liveGameID, organizationID(ctx)))
+}
+
+// StartLiveGame kicks off a game with no goals. Scores sent with the teams
+// are ignored; they come from the goals recorded later.
+func (r *Repository) StartLiveGame(ctx context.Context, req models.StartLiveGameRequest) (*models.LiveGame, error) {
+	if len(req.Teams) != 2 {
+		return nil, fmt.Errorf("exactly 2 teams required")
+	}
+	ids, err := teamPlayerIDs(req.Teams)
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := getPlayersByIDs(ctx, r.db, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := players[id]; !ok {
+			return nil, fmt.Errorf("player %d not found", id)
+		}
+	}
+	if _, err := tableMultiplier(ctx, r.db, req.TableID); err != nil {
+		return nil, err
+	}
+
+	teams := make([]models.CreateGameTeam, len(req.Teams))
+	for i, team := range req.Teams {
+		teams[i] = models.CreateGameTeam{PlayerIDs: team.PlayerIDs, Side: team.Side}
+	}
+
+	return scanLiveGame(r.db.QueryRow(ctx,
+		`INSERT INTO live_games (organization_id, game_type, table_id, teams) VALUES ($1, $2, $3, $4)
+		 RETURNING `+liveGameColumns,
+		organizationID(ctx), req.GameType, req.TableID, teams,
+	))
+}
+
+// AddLiveGoal appends a goal to a live game's timeline. Goals sent without
+// elapsed_seconds are timed from when the game started.
+func (r *Repository) AddLiveGoal(ctx context.Context, liveGameID int, goal models.Goal) (*models.LiveGame, error) {
+	return r.updateLiveGoals(ctx, liveGameID, func(goals []models.Goal, elapsed int) ([]models.Goal, error) {
+		if goal.ElapsedSeconds == nil {
+			goal.ElapsedSeconds = &elapsed
+		}
+		return append(goals, goal), nil
+	})
+}
+
+// UndoLiveGoal takes back the most recent goal of a live game.
+func (r *Repository) UndoLiveGoal(ctx context.Context, liveGameID int) (*models.LiveGame, error) {
+	return r.updateLiveGoals(ctx, liveGameID, func(goals []models.Goal, elapsed int) ([]models.Goal, error) {
+		if len(goals) == 0 {
+			return nil, fmt.Errorf("%w: no goals to undo", ledger.ErrInvalidGoals)
+		}
+		return goals[:len(goals)-1], nil
+	})
+}
+
+func (r *Repository) updateLiveGoals(ctx context.Context, liveGameID int, change func(goals []models.Goal, elapsed int) ([]models.Goal, error)) (*models.LiveGame, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var elapsed int
+	err = tx.QueryRow(ctx,
+		`SELECT EXTRACT(EPOCH FROM NOW()::timestamp - started_at)::int FROM live_games
+		 WHERE id = $1 AND organization_id = $2 FOR UPDATE`,
+		liveGameID, organizationID(ctx)).
+		Scan(&elapsed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLiveGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := scanLiveGame(tx.QueryRow(ctx, `SELECT `+liveGameColumns+` FROM live_games WHERE id = $1`, liveGameID))
+	if err != nil {
+		return nil, err
+	}
+
+	goals, err := change(before.Goals, elapsed)
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]models.CreateGameTeam, len(before.Teams))
+	for i, team := range before.Teams {
+		teams[i] = models.CreateGameTeam{PlayerIDs: team.PlayerIDs, Side: team.Side}
+	}
+	if err := ledger.ScoreGoals(teams, goals); err != nil {
+		return nil, err
+	}
+
+	after, err := scanLiveGame(tx.QueryRow(ctx,
+		`UPDATE live_games SET goals = $2, version = version + 1 WHERE id = $1 RETURNING `+liveGameColumns,
+		liveGameID, goals,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// FinishLiveGame records a live game as a rated game played when it
+// started, and ends it. version is the version of the live game the caller
+// decided to record, and it is not finished if it has been scored since.
+func (r *Repository) FinishLiveGame(ctx context.Context, liveGameID, version int) (*models.Game, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The game is recorded as backdated to its start, which takes the
+	// exclusive ledger lock; take it before touching any rows.
+	if err := lockLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := endLiveGame(ctx, tx, liveGameID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	game, err := createGame(ctx, tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := changes.addGame(ChangeGameRecorded, game); err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// SubmitLiveGame ends a live game and holds it, as played when it started,
+// for the losing team to confirm, like SubmitGame. version is as for
+// FinishLiveGame.
+func (r *Repository) SubmitLiveGame(ctx context.Context, liveGameID, version int) (*models.PendingGame, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	req, err := endLiveGame(ctx, tx, liveGameID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := submitGame(ctx, tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// endLiveGame deletes a live game that has been scored and is still at
+// version, and returns the game to record for it.
+func endLiveGame(ctx context.Context, tx pgx.Tx, liveGameID, version int) (models.CreateGameRequest, error) {
+	live, err := scanLiveGame(tx.QueryRow(ctx,
+		`DELETE FROM live_games WHERE id = $1 AND organization_id = $2 RETURNING `+liveGameColumns,
+		liveGameID, organizationID(ctx)))
+	if err != nil {
+		return models.CreateGameRequest{}, err
+	}
+	if err := checkVersion(&version, live.Version); err != nil {
+		return models.CreateGameRequest{}, err
+	}
+	if len(live.Goals) == 0 {
+		return models.CreateGameRequest{}, fmt.Errorf("%w: no goals have been scored", ledger.ErrInvalidGoals)
+	}
+
+	return models.CreateGameRequest{
+		GameType: live.GameType,
+		Teams:    live.Teams,
+		TableID:  live.TableID,
+		PlayedAt: &live.StartedAt,
+		Goals:    live.Goals,
+	}, nil
+}
+
+// AbandonLiveGame ends a live game without recording it.
+func (r *Repository) AbandonLiveGame(ctx context.Context, liveGameID int) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM live_games WHERE id = $1 AND organization_id = $2`, liveGameID, organizationID(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLiveGameNotFound
+	}
+	return nil
+}
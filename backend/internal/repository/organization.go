@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+const DefaultOrganization = "default"
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("organization already exists")
+)
+
+type organizationKey struct{}
+
+// WithOrganization scopes every repository call made with ctx to org.
+func WithOrganization(ctx context.Context, org *models.Organization) context.Context {
+	return context.WithValue(ctx, organizationKey{}, org)
+}
+
+// OrganizationFromContext returns the organization ctx is scoped to, if any.
+func OrganizationFromContext(ctx context.Context) (*models.Organization, bool) {
+	org, ok := ctx.Value(organizationKey{}).(*models.Organization)
+	return org, ok && org != nil
+}
+
+// organizationID is the tenant every query filters on. Without an
+// organization it is 0, which matches no rows, so a call that was never
+// scoped sees an empty database rather than somebody else's.
+func organizationID(ctx context.Context) int {
+	if org, ok := OrganizationFromContext(ctx); ok {
+		return org.ID
+	}
+	return 0
+}
+
+// calculator reads the rating settings of the organization ctx is scoped to.
+// They are read inside the transaction, after the ledger lock, rather than
+// taken from ctx, so a game is never rated with settings that changed while
+// the request was in flight.
+func calculator(ctx context.Context, q querier) (elo.Calculator, error) {
+	var calc elo.Calculator
+	var kFactor int
+	err := q.QueryRow(ctx, `SELECT rating_system, k_factor FROM organizations WHERE id = $1`, organizationID(ctx)).
+		Scan(&calc.System, &kFactor)
+	calc.KFactor = float64(kFactor)
+	return calc, err
+}
+
+const organizationColumns = `id, slug, name, rating_system, k_factor, game_formats, require_confirmation, confirmation_hours, anonymous_role, version, created_at`
+
+func scanOrganization(row pgx.Row) (*models.Organization, error) {
+	var org models.Organization
+	err := row.Scan(&org.ID, &org.Slug, &org.Name, &org.RatingSystem, &org.KFactor, &org.GameFormats, &org.RequireConfirmation, &org.ConfirmationHours, &org.AnonymousRole, &org.Version, &org.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *Repository) GetOrganizationBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	org, err := scanOrganization(r.db.QueryRow(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE slug = $1`, slug))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrganizationNotFound
+	}
+	return org, err
+}
+
+func (r *Repository) ListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+organizationColumns+` FROM organizations ORDER BY slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, *org)
+	}
+	return orgs, rows.Err()
+}
+
+// OrganizationTotals counts every organization's games and players, for
+// monitoring rather than any one organization's callers.
+func (r *Repository) OrganizationTotals(ctx context.Context) ([]models.OrganizationTotals, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT o.slug,
+		       (SELECT COUNT(*) FROM games g WHERE g.organization_id = o.id),
+		       (SELECT COUNT(*) FROM players p WHERE p.organization_id = o.id),
+		       (SELECT COUNT(DISTINCT gp.player_id) FROM game_participants gp JOIN games g ON g.id = gp.game_id
+		        WHERE g.organization_id = o.id AND g.played_at > NOW() - INTERVAL '30 days'),
+		       (SELECT COALESCE(SUM(p.rating), 0) FROM players p WHERE p.organization_id = o.id)
+		FROM organizations o
+		ORDER BY o.slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []models.OrganizationTotals{}
+	for rows.Next() {
+		var t models.OrganizationTotals
+		if err := rows.Scan(&t.Slug, &t.Games, &t.Players, &t.ActivePlayers, &t.TotalRating); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+func (r *Repository) CreateOrganization(ctx context.Context, req models.CreateOrganizationRequest) (*models.Organization, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	org, err := scanOrganization(tx.QueryRow(ctx,
+		`INSERT INTO organizations (slug, name, rating_system, k_factor, game_formats, require_confirmation, confirmation_hours, anonymous_role)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING `+organizationColumns,
+		req.Slug, req.Name, req.RatingSystem, req.KFactor, req.GameFormats, req.RequireConfirmation, req.ConfirmationHours, req.AnonymousRole,
+	))
+	if isUniqueViolation(err) {
+		return nil, ErrOrganizationExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(WithOrganization(ctx, org), tx, AuditCreate, AuditEntityOrganization, org.ID, nil, org); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// UpdateOrganization renames the organization ctx is scoped to and replaces
+// its settings. Ratings are always computed with the current settings, so
+// the ledger is replayed to re-rate every game with them.
+func (r *Repository) UpdateOrganization(ctx context.Context, name string, settings models.OrganizationSettings, expectedVersion *int) (*models.Organization, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := scanOrganization(tx.QueryRow(ctx,
+		`SELECT `+organizationColumns+` FROM organizations WHERE id = $1 FOR UPDATE`, organizationID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(expectedVersion, before.Version); err != nil {
+		return nil, err
+	}
+
+	after, err := scanOrganization(tx.QueryRow(ctx,
+		`UPDATE organizations SET name = $2, rating_system = $3, k_factor = $4, game_formats = $5,
+		     require_confirmation = $6, confirmation_hours = $7, anonymous_role = $8, version = version + 1
+		 WHERE id = $1
+		 RETURNING `+organizationColumns,
+		before.ID, name, settings.RatingSystem, settings.KFactor, settings.GameFormats, settings.RequireConfirmation, settings.ConfirmationHours, settings.AnonymousRole,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = WithOrganization(ctx, after)
+	if after.RatingSystem != before.RatingSystem || after.KFactor != before.KFactor {
+		if err := rebuildProjections(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordAudit(ctx, tx, AuditUpdate, AuditEntityOrganization, after.ID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+func createOrganization(t *testing.T, repo *Repository, slug string) context.Context {
+	t.Helper()
+	org, err := repo.CreateOrganization(context.Background(), models.CreateOrganizationRequest{
+		Slug: slug,
+		Name: slug,
+		OrganizationSettings: models.OrganizationSettings{
+			RatingSystem:      "elo",
+			KFactor:           32,
+			GameFormats:       []string{"singles", "doubles"},
+			ConfirmationHours: 48,
+			AnonymousRole:     RoleNone,
+		},
+	})
+	if err != nil {
+		t.Fatalf("creating organization %q: %v", slug, err)
+	}
+	return WithOrganization(context.Background(), org)
+}
+
+// TestOrganizationsAreIsolated fills one organization and checks that
+// another sees none of it and cannot change any of it by ID.
+func TestOrganizationsAreIsolated(t *testing.T) {
+	repo, home := newTestRepository(t)
+	other := createOrganization(t, repo, "other")
+
+	players := createPlayers(t, home, repo, 2)
+	game, err := repo.CreateGame(home, singlesGame(players[0], players[1], 4))
+	if err != nil {
+		t.Fatalf("recording game: %v", err)
+	}
+	if _, err := repo.CreateTable(home, models.TableRequest{Name: "Kitchen"}); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	if _, err := repo.CreateAPIKey(home, models.APIKeyRequest{Name: "bot", Role: RoleRecorder}); err != nil {
+		t.Fatalf("creating API key: %v", err)
+	}
+	before := standings(t, home, repo)
+
+	t.Run("reads", func(t *testing.T) {
+		if players, err := repo.ListPlayers(other); err != nil || len(players) != 0 {
+			t.Errorf("ListPlayers = %d players, %v; want none", len(players), err)
+		}
+		if _, err := repo.GetPlayerByID(other, players[0]); err == nil {
+			t.Error("GetPlayerByID found another organization's player")
+		}
+		if _, err := repo.GetGameByID(other, game.ID); err == nil {
+			t.Error("GetGameByID found another organization's game")
+		}
+		if page, err := repo.ListGames(other, models.GameFilter{Limit: 50}); err != nil || len(page.Games) != 0 {
+			t.Errorf("ListGames = %v, %v; want no games", page, err)
+		}
+		if entries, err := repo.GetLeaderboard(other); err != nil || len(entries) != 0 {
+			t.Errorf("GetLeaderboard = %d entries, %v; want none", len(entries), err)
+		}
+		if tables, err := repo.ListTables(other); err != nil || len(tables) != 0 {
+			t.Errorf("ListTables = %d tables, %v; want none", len(tables), err)
+		}
+		if keys, err := repo.ListAPIKeys(other); err != nil || len(keys) != 0 {
+			t.Errorf("ListAPIKeys = %d keys, %v; want none", len(keys), err)
+		}
+		if events, err := repo.ListEvents(other, 0, 100); err != nil || len(events) != 0 {
+			t.Errorf("ListEvents = %d events, %v; want none", len(events), err)
+		}
+		if entries, err := repo.ListAuditLog(other, models.AuditFilter{Limit: 100}); err != nil || len(entries) != 0 {
+			t.Errorf("ListAuditLog = %d entries, %v; want none", len(entries), err)
+		}
+	})
+
+	t.Run("writes", func(t *testing.T) {
+		if _, err := repo.CreateGame(other, singlesGame(players[0], players[1], 0)); err == nil {
+			t.Error("CreateGame rated another organization's players")
+		}
+		if _, err := repo.UpdatePlayer(other, strconv.Itoa(players[0]), "Renamed", nil); err == nil {
+			t.Error("UpdatePlayer renamed another organization's player")
+		}
+		if _, err := repo.UpdateGame(other, strconv.Itoa(game.ID), 10, 0, nil, nil); err == nil {
+			t.Error("UpdateGame corrected another organization's game")
+		}
+		if err := repo.DeleteGame(other, strconv.Itoa(game.ID), nil); err == nil {
+			t.Error("DeleteGame voided another organization's game")
+		}
+		if err := repo.DeletePlayer(other, strconv.Itoa(players[0]), nil); err == nil {
+			t.Error("DeletePlayer deleted another organization's player")
+		}
+		if err := repo.RebuildProjections(other); err != nil {
+			t.Fatalf("replaying the other organization's ledger: %v", err)
+		}
+	})
+
+	after := standings(t, home, repo)
+	if len(after) != len(before) {
+		t.Fatalf("home organization has %d players, want %d", len(after), len(before))
+	}
+	for id, p := range before {
+		if after[id].Name != p.Name || after[id].Rating != p.Rating || after[id].GamesPlayed != p.GamesPlayed {
+			t.Errorf("player %d changed from %+v to %+v", id, p, after[id])
+		}
+	}
+	if _, err := repo.GetGameByID(home, game.ID); err != nil {
+		t.Errorf("home organization lost its game: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/logging"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var ErrPendingGameNotFound = errors.New("pending game not found")
+
+// Statuses of a pending game.
+const (
+	PendingStatusPending   = "pending"
+	PendingStatusConfirmed = "confirmed"
+	PendingStatusRejected  = "rejected"
+)
+
+// autoConfirmActor is who games confirmed for running out of time are
+// attributed to.
+const autoConfirmActor = "system:auto-confirm"
+
+// overdueBatchSize bounds how many overdue games ConfirmOverdueGames
+// confirms in one call.
+const overdueBatchSize = 100
+
+// overdueRetryDelay is how long ConfirmOverdueGames leaves a game that
+// failed to confirm before trying it again.
+const overdueRetryDelay = time.Hour
+
+// pendingGameQuery selects pending games, with the username of whoever
+// submitted them, from the rows of pg.
+const pendingGameQuery = `SELECT pg.id, pg.request, pg.confirmable_by, pg.status, pg.submitted_by, u.username, pg.submitted_at,
+	pg.confirm_by, pg.resolved_by, pg.resolved_at, pg.game_id
+	FROM pg LEFT JOIN users u ON u.id = pg.submitted_by`
+
+func scanPendingGame(row pgx.Row) (*models.PendingGame, error) {
+	var g models.PendingGame
+	var submitterID *int
+	var submitterName *string
+	err := row.Scan(&g.ID, &g.Game, &g.ConfirmableBy, &g.Status, &submitterID, &submitterName, &g.SubmittedAt,
+		&g.ConfirmBy, &g.ResolvedBy, &g.ResolvedAt, &g.GameID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPendingGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.SubmittedBy = gameSubmitter(submitterID, submitterName)
+	return &g, nil
+}
+
+// LosingPlayerIDs returns the players of the team with the lower score,
+// who are the ones to confirm a game. After a draw either team can.
+func LosingPlayerIDs(teams []models.CreateGameTeam) []int {
+	if len(teams) != 2 {
+		return nil
+	}
+	switch {
+	case teams[0].Score < teams[1].Score:
+		return teams[0].PlayerIDs
+	case teams[1].Score < teams[0].Score:
+		return teams[1].PlayerIDs
+	}
+	return append(append([]int{}, teams[0].PlayerIDs...), teams[1].PlayerIDs...)
+}
+
+// SubmitGame holds a game for the losing team to confirm instead of rating
+// it straight away. It must be confirmed within the organization's
+// confirmation hours, or it is confirmed automatically.
+func (r *Repository) SubmitGame(ctx context.Context, req models.CreateGameRequest) (*models.PendingGame, error) {
+	return submitGame(ctx, r.db, req)
+}
+
+func submitGame(ctx context.Context, q querier, req models.CreateGameRequest) (*models.PendingGame, error) {
+	if len(req.Teams) != 2 {
+		return nil, fmt.Errorf("exactly 2 teams required")
+	}
+	ids, err := teamPlayerIDs(req.Teams)
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := getPlayersByIDs(ctx, q, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := players[id]; !ok {
+			return nil, fmt.Errorf("player %d not found", id)
+		}
+	}
+	if _, err := tableMultiplier(ctx, q, req.TableID); err != nil {
+		return nil, err
+	}
+
+	return scanPendingGame(q.QueryRow(ctx,
+		`WITH pg AS (
+			INSERT INTO pending_games (organization_id, request, player_ids, confirmable_by, submitted_by, confirm_by)
+			SELECT id, $2, $3, $4, $5, NOW() + make_interval(hours => confirmation_hours)
+			FROM organizations WHERE id = $1
+			RETURNING *
+		) `+pendingGameQuery,
+		organizationID(ctx), req, ids, LosingPlayerIDs(req.Teams), userIDFromContext(ctx),
+	))
+}
+
+// ListPendingGames returns the organization's games with status, the ones
+// due to be confirmed soonest first.
+func (r *Repository) ListPendingGames(ctx context.Context, status string, limit int) ([]models.PendingGame, error) {
+	rows, err := r.db.Query(ctx,
+		`WITH pg AS (
+			SELECT * FROM pending_games WHERE organization_id = $1 AND status = $2
+		) `+pendingGameQuery+`
+		ORDER BY pg.confirm_by, pg.id
+		LIMIT $3`,
+		organizationID(ctx), status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []models.PendingGame{}
+	for rows.Next() {
+		g, err := scanPendingGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, *g)
+	}
+	return games, rows.Err()
+}
+
+func (r *Repository) GetPendingGame(ctx context.Context, pendingGameID int) (*models.PendingGame, error) {
+	return scanPendingGame(r.db.QueryRow(ctx,
+		`WITH pg AS (
+			SELECT * FROM pending_games WHERE id = $1 AND organization_id = $2
+		) `+pendingGameQuery,
+		pendingGameID, organizationID(ctx)))
+}
+
+// resolvePendingGame marks a game that is still pending as confirmed or
+// rejected by the caller.
+func resolvePendingGame(ctx context.Context, q querier, pendingGameID int, status string) (*models.PendingGame, error) {
+	return scanPendingGame(q.QueryRow(ctx,
+		`WITH pg AS (
+			UPDATE pending_games SET status = $3, resolved_by = $4, resolved_at = NOW()
+			WHERE id = $1 AND organization_id = $2 AND status = 'pending'
+			RETURNING *
+		) `+pendingGameQuery,
+		pendingGameID, organizationID(ctx), status, actorFromContext(ctx)))
+}
+
+// ConfirmPendingGame records and rates a pending game as played when it was
+// submitted, unless it says otherwise. The game counts as submitted by
+// whoever submitted it for confirmation rather than whoever confirms it.
+func (r *Repository) ConfirmPendingGame(ctx context.Context, pendingGameID int) (*models.Game, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The game is backdated to when it was submitted, which takes the
+	// exclusive ledger lock; take it before touching any rows.
+	if err := lockLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := resolvePendingGame(ctx, tx, pendingGameID, PendingStatusConfirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	req := pending.Game
+	if req.PlayedAt == nil {
+		req.PlayedAt = &pending.SubmittedAt
+	}
+	ctx = context.WithValue(ctx, userKey{}, nil)
+	if pending.SubmittedBy != nil {
+		ctx = WithUser(ctx, pending.SubmittedBy.UserID)
+	}
+	game, err := createGame(ctx, tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE pending_games SET game_id = $1 WHERE id = $2`, game.ID, pending.ID); err != nil {
+		return nil, err
+	}
+
+	if err := changes.addGame(ChangeGameRecorded, game); err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// RejectPendingGame discards a pending game without rating it.
+func (r *Repository) RejectPendingGame(ctx context.Context, pendingGameID int) (*models.PendingGame, error) {
+	return resolvePendingGame(ctx, r.db, pendingGameID, PendingStatusRejected)
+}
+
+// ConfirmOverdueGames confirms games, in every organization, that have been
+// pending for longer than their organization allows, and returns how many
+// it confirmed. It carries on past games that fail to confirm, returning
+// their errors together, and leaves them for overdueRetryDelay so they do
+// not crowd out the rest.
+func (r *Repository) ConfirmOverdueGames(ctx context.Context) (int, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, organization_id FROM pending_games
+		 WHERE status = 'pending' AND confirm_by <= NOW()
+		   AND (auto_confirm_retry_at IS NULL OR auto_confirm_retry_at <= NOW())
+		 ORDER BY COALESCE(auto_confirm_retry_at, confirm_by), id
+		 LIMIT $1`, overdueBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	overdue := map[int][]int{}
+	var orgIDs []int
+	for rows.Next() {
+		var id, orgID int
+		if err := rows.Scan(&id, &orgID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if _, ok := overdue[orgID]; !ok {
+			orgIDs = append(orgIDs, orgID)
+		}
+		overdue[orgID] = append(overdue[orgID], id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	confirmed := 0
+	var errs []error
+	for _, orgID := range orgIDs {
+		org, err := scanOrganization(r.db.QueryRow(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE id = $1`, orgID))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		orgCtx := WithActor(WithOrganization(ctx, org), autoConfirmActor)
+		orgCtx = logging.With(orgCtx, "organization", org.Slug)
+		for _, id := range overdue[orgID] {
+			_, err := r.ConfirmPendingGame(logging.With(orgCtx, "pending_game_id", id), id)
+			if errors.Is(err, ErrPendingGameNotFound) {
+				// Confirmed or rejected by someone in the meantime.
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pending game %d: %w", id, err))
+				if _, err := r.db.Exec(ctx,
+					`UPDATE pending_games SET auto_confirm_retry_at = NOW() + $2 * INTERVAL '1 second' WHERE id = $1`,
+					id, overdueRetryDelay.Seconds()); err != nil {
+					errs = append(errs, fmt.Errorf("pending game %d: %w", id, err))
+				}
+				continue
+			}
+			confirmed++
+		}
+	}
+	return confirmed, errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+func TestSubmitLiveGame(t *testing.T) {
+	repo, ctx := newTestRepository(t)
+	ids := createPlayers(t, ctx, repo, 2)
+
+	live, err := repo.StartLiveGame(ctx, models.StartLiveGameRequest{
+		GameType: "singles",
+		Teams:    []models.CreateGameTeam{{PlayerIDs: ids[:1]}, {PlayerIDs: ids[1:]}},
+	})
+	if err != nil {
+		t.Fatalf("starting live game: %v", err)
+	}
+	live, err = repo.AddLiveGoal(ctx, live.ID, models.Goal{PlayerID: &ids[0], Team: 1})
+	if err != nil {
+		t.Fatalf("scoring live game: %v", err)
+	}
+
+	if _, err := repo.SubmitLiveGame(ctx, live.ID, live.Version-1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("submitting a stale live game = %v, want %v", err, ErrVersionMismatch)
+	}
+	pending, err := repo.SubmitLiveGame(ctx, live.ID, live.Version)
+	if err != nil {
+		t.Fatalf("submitting live game: %v", err)
+	}
+	if pending.Status != PendingStatusPending || len(pending.ConfirmableBy) != 1 || pending.ConfirmableBy[0] != ids[1] {
+		t.Errorf("submitted %+v, want a game for player %d to confirm", pending, ids[1])
+	}
+	if pending.Game.PlayedAt == nil || !pending.Game.PlayedAt.Equal(live.StartedAt) {
+		t.Errorf("submitted game played at %v, want %v", pending.Game.PlayedAt, live.StartedAt)
+	}
+	if _, err := repo.GetLiveGame(ctx, live.ID); !errors.Is(err, ErrLiveGameNotFound) {
+		t.Errorf("live game after submitting = %v, want %v", err, ErrLiveGameNotFound)
+	}
+	if standings(t, ctx, repo)[ids[0]].GamesPlayed != 0 {
+		t.Error("submitting a live game rated it before it was confirmed")
+	}
+}
+
+// TestConfirmOverdueGamesSetsFailuresAside checks that a game that cannot be
+// confirmed is not tried again straight away, ahead of the games behind it.
+func TestConfirmOverdueGamesSetsFailuresAside(t *testing.T) {
+	repo, ctx := newTestRepository(t)
+	ids := createPlayers(t, ctx, repo, 2)
+
+	var pendingIDs []int
+	for i := 0; i < 2; i++ {
+		pending, err := repo.SubmitGame(ctx, singlesGame(ids[0], ids[1], 4))
+		if err != nil {
+			t.Fatalf("submitting game: %v", err)
+		}
+		pendingIDs = append(pendingIDs, pending.ID)
+	}
+	// The first game is due first and can no longer be recorded.
+	if _, err := repo.db.Exec(ctx, `UPDATE pending_games SET confirm_by = NOW() - INTERVAL '2 hours', request = '{}' WHERE id = $1`, pendingIDs[0]); err != nil {
+		t.Fatalf("breaking pending game: %v", err)
+	}
+	if _, err := repo.db.Exec(ctx, `UPDATE pending_games SET confirm_by = NOW() - INTERVAL '1 hour' WHERE id = $1`, pendingIDs[1]); err != nil {
+		t.Fatalf("expiring pending game: %v", err)
+	}
+
+	confirmed, err := repo.ConfirmOverdueGames(ctx)
+	if confirmed != 1 || err == nil {
+		t.Errorf("first run confirmed %d (error %v), want 1 and the broken game's error", confirmed, err)
+	}
+	confirmed, err = repo.ConfirmOverdueGames(ctx)
+	if confirmed != 0 || err != nil {
+		t.Errorf("second run confirmed %d (error %v), want the broken game left alone", confirmed, err)
+	}
+
+	var retryAt *string
+	if err := repo.db.QueryRow(ctx, `SELECT auto_confirm_retry_at::text FROM pending_games WHERE id = $1`, pendingIDs[0]).Scan(&retryAt); err != nil {
+		t.Fatalf("reading pending game: %v", err)
+	}
+	if retryAt == nil {
+		t.Error("the broken game was not set aside")
+	}
+}
@@ -2,39 +2,86 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
 	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/stream"
 )
 
+// ErrVersionMismatch is returned when a caller's expected version of a row
+// no longer matches the stored one because someone else changed it first.
+var ErrVersionMismatch = errors.New("version mismatch")
+
 type Repository struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	changes *stream.Broker
 }
 
 func New(db *pgxpool.Pool) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, changes: stream.NewBroker()}
 }
 
 func (r *Repository) CreatePlayer(ctx context.Context, name string) (*models.Player, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := joinLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
 	var player models.Player
-	err := r.db.QueryRow(ctx,
-		`INSERT INTO players (name, rating) VALUES ($1, $2) RETURNING id, name, rating, games_played, created_at`,
-		name, elo.InitialRating,
-	).Scan(&player.ID, &player.Name, &player.Rating, &player.GamesPlayed, &player.CreatedAt)
-	return &player, err
+	err = tx.QueryRow(ctx,
+		`INSERT INTO players (organization_id, name, rating) VALUES ($1, $2, $3) RETURNING id, name, rating, games_played, version, created_at`,
+		organizationID(ctx), name, elo.InitialRating,
+	).Scan(&player.ID, &player.Name, &player.Rating, &player.GamesPlayed, &player.Version, &player.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	created := ledger.PlayerCreatedPayload{PlayerID: player.ID, Name: player.Name, CreatedAt: player.CreatedAt}
+	if err := appendEvent(ctx, tx, ledger.PlayerCreated, player.ID, created); err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditCreate, AuditEntityPlayer, player.ID, nil, &player); err != nil {
+		return nil, err
+	}
+
+	if err := changes.add(ChangePlayerCreated, &player, player.ID); err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return &player, nil
 }
 
 func (r *Repository) ListPlayers(ctx context.Context) ([]models.LeaderboardEntry, error) {
 	rows, err := r.db.Query(ctx,
-		`SELECT p.id, p.name, p.rating, p.games_played, p.created_at,
+		`SELECT p.id, p.name, p.rating, p.games_played, p.version, p.created_at,
 		        COUNT(CASE WHEN gp.rating_after > gp.rating_before THEN 1 END) as wins,
 		        COUNT(CASE WHEN gp.rating_after < gp.rating_before THEN 1 END) as losses
 		 FROM players p
 		 LEFT JOIN game_participants gp ON p.id = gp.player_id
+		 WHERE p.organization_id = $1
 		 GROUP BY p.id
-		 ORDER BY rating DESC`)
+		 ORDER BY rating DESC`, organizationID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +90,7 @@ func (r *Repository) ListPlayers(ctx context.Context) ([]models.LeaderboardEntry
 	var players []models.LeaderboardEntry
 	for rows.Next() {
 		var p models.LeaderboardEntry
-		if err := rows.Scan(&p.ID, &p.Name, &p.Rating, &p.GamesPlayed, &p.CreatedAt, &p.Wins, &p.Losses); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Rating, &p.GamesPlayed, &p.Version, &p.CreatedAt, &p.Wins, &p.Losses); err != nil {
 			return nil, err
 		}
 		players = append(players, p)
@@ -52,7 +99,19 @@ func (r *Repository) ListPlayers(ctx context.Context) ([]models.LeaderboardEntry
 }
 
 func (r *Repository) GetPlayersByIDs(ctx context.Context, ids []int) (map[int]*models.Player, error) {
-	rows, err := r.db.Query(ctx, `SELECT id, name, rating, games_played, created_at FROM players WHERE id = ANY($1)`, ids)
+	return getPlayersByIDs(ctx, r.db, ids, false)
+}
+
+// getPlayersByIDs optionally locks the returned rows so ratings read inside a
+// transaction cannot change before that transaction writes them back. Rows
+// are locked in ID order so concurrent games never deadlock on each other.
+func getPlayersByIDs(ctx context.Context, q querier, ids []int, forUpdate bool) (map[int]*models.Player, error) {
+	query := `SELECT id, name, rating, games_played, version, created_at FROM players WHERE id = ANY($1) AND organization_id = $2 ORDER BY id`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	rows, err := q.Query(ctx, query, ids, organizationID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +120,7 @@ func (r *Repository) GetPlayersByIDs(ctx context.Context, ids []int) (map[int]*m
 	players := make(map[int]*models.Player)
 	for rows.Next() {
 		var p models.Player
-		if err := rows.Scan(&p.ID, &p.Name, &p.Rating, &p.GamesPlayed, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Rating, &p.GamesPlayed, &p.Version, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		players[p.ID] = &p
@@ -70,26 +129,78 @@ func (r *Repository) GetPlayersByIDs(ctx context.Context, ids []int) (map[int]*m
 }
 
 func (r *Repository) CreateGame(ctx context.Context, req models.CreateGameRequest) (*models.Game, error) {
-	if len(req.Teams) != 2 {
-		return nil, fmt.Errorf("exactly 2 teams required")
-	}
-
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
-	var allPlayerIDs []int
-	for _, team := range req.Teams {
-		allPlayerIDs = append(allPlayerIDs, team.PlayerIDs...)
+	if err := lockLedgerFor(ctx, tx, req); err != nil {
+		return nil, err
 	}
 
-	players, err := r.GetPlayersByIDs(ctx, allPlayerIDs)
+	changes, err := trackChanges(ctx, tx)
 	if err != nil {
 		return nil, err
 	}
 
+	game, err := createGame(ctx, tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := changes.addGame(ChangeGameRecorded, game); err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// lockLedgerFor takes the ledger lock that recording req needs. A backdated
+// game may land before games already recorded, which then have to be
+// re-rated by a full replay that needs the exclusive lock. Games with sides
+// take it too, so the side advantage they are rated with includes every game
+// recorded before them, as it does on replay.
+func lockLedgerFor(ctx context.Context, tx pgx.Tx, req models.CreateGameRequest) error {
+	if req.PlayedAt != nil || hasSides(req.Teams) {
+		return lockLedger(ctx, tx)
+	}
+	return joinLedger(ctx, tx)
+}
+
+// hasSides reports whether both teams say which side of the table they
+// played on.
+func hasSides(teams []models.CreateGameTeam) bool {
+	return len(teams) == 2 && teams[0].Side != "" && teams[1].Side != ""
+}
+
+// createGame records and rates req. The caller must already hold the ledger
+// lock that lockLedgerFor takes for it.
+func createGame(ctx context.Context, tx pgx.Tx, req models.CreateGameRequest) (*models.Game, error) {
+	if len(req.Teams) != 2 {
+		return nil, fmt.Errorf("exactly 2 teams required")
+	}
+	backdated := req.PlayedAt != nil
+	sided := hasSides(req.Teams)
+
+	allPlayerIDs, err := teamPlayerIDs(req.Teams)
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := getPlayersByIDs(ctx, tx, allPlayerIDs, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range allPlayerIDs {
+		if _, ok := players[id]; !ok {
+			return nil, fmt.Errorf("player %d not found", id)
+		}
+	}
+
 	team1Ratings := make([]int, len(req.Teams[0].PlayerIDs))
 	for i, id := range req.Teams[0].PlayerIDs {
 		team1Ratings[i] = players[id].Rating
@@ -100,19 +211,45 @@ func (r *Repository) CreateGame(ctx context.Context, req models.CreateGameReques
 		team2Ratings[i] = players[id].Rating
 	}
 
-	deltaTeam1, deltaTeam2 := elo.CalculateNewRatings(
-		elo.AverageRating(team1Ratings),
+	calc, err := calculator(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	multiplier, err := tableMultiplier(ctx, tx, req.TableID)
+	if err != nil {
+		return nil, err
+	}
+
+	var advantage float64
+	if sided {
+		sides, err := sideRecords(ctx, tx, nil)
+		if err != nil {
+			return nil, err
+		}
+		advantage = sides.Advantage(req.Teams[0].Side, req.Teams[1].Side)
+	}
+
+	deltaTeam1, deltaTeam2 := calc.Scaled(multiplier).NewRatings(
+		elo.AverageRating(team1Ratings)+advantage,
 		elo.AverageRating(team2Ratings),
-		req.Teams[0].Score > req.Teams[1].Score,
+		req.Teams[0].Score,
+		req.Teams[1].Score,
 	)
 
+	// Games played now are stamped with the time of the insert, which comes
+	// after the player locks, rather than NOW(), the start of the
+	// transaction. Games sharing a player then sort in the order they were
+	// rated, so replaying the ledger rates them the same way.
 	var gameID int
-	err = tx.QueryRow(ctx, `INSERT INTO games (game_type) VALUES ($1) RETURNING id`, req.GameType).Scan(&gameID)
+	err = tx.QueryRow(ctx,
+		`INSERT INTO games (organization_id, game_type, table_id, played_at, submitted_by) VALUES ($1, $2, $3, COALESCE($4, clock_timestamp()), $5) RETURNING id`,
+		organizationID(ctx), req.GameType, req.TableID, req.PlayedAt, userIDFromContext(ctx),
+	).Scan(&gameID)
 	if err != nil {
 		return nil, err
 	}
 
-	gamePlayers := []models.GamePlayer{}
 	for teamNum, team := range req.Teams {
 		delta := deltaTeam1
 		if teamNum == 1 {
@@ -124,100 +261,267 @@ func (r *Repository) CreateGame(ctx context.Context, req models.CreateGameReques
 			newRating := player.Rating + delta
 
 			_, err = tx.Exec(ctx,
-				`INSERT INTO game_participants (game_id, player_id, team, score, rating_before, rating_after) VALUES ($1, $2, $3, $4, $5, $6)`,
-				gameID, playerID, teamNum+1, team.Score, player.Rating, newRating,
+				`INSERT INTO game_participants (game_id, player_id, team, side, score, rating_before, rating_after) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				gameID, playerID, teamNum+1, sideValue(team.Side), team.Score, player.Rating, newRating,
 			)
 			if err != nil {
 				return nil, err
 			}
 
-			_, err = tx.Exec(ctx, `UPDATE players SET rating = $1, games_played = games_played + 1 WHERE id = $2`, newRating, playerID)
+			_, err = tx.Exec(ctx, `UPDATE players SET rating = $1, games_played = games_played + 1, version = version + 1 WHERE id = $2`, newRating, playerID)
 			if err != nil {
 				return nil, err
 			}
+		}
+	}
 
-			gamePlayers = append(gamePlayers, models.GamePlayer{
-				PlayerID:     playerID,
-				PlayerName:   player.Name,
-				Team:         teamNum + 1,
-				Score:        team.Score,
-				RatingBefore: player.Rating,
-				RatingAfter:  newRating,
-			})
+	if err := copyGoals(ctx, tx, goalRows(gameID, req.Goals)); err != nil {
+		return nil, err
+	}
+
+	game, err := getGame(ctx, tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := ledger.GameRecordedPayload{GameID: gameID, GameType: req.GameType, Teams: req.Teams, TableID: req.TableID, PlayedAt: &game.PlayedAt, Goals: req.Goals, CreatedAt: game.CreatedAt}
+	if err := appendEvent(ctx, tx, ledger.GameRecorded, gameID, recorded); err != nil {
+		return nil, err
+	}
+
+	if backdated {
+		var later bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM games WHERE organization_id = $1 AND played_at > $2)`,
+			organizationID(ctx), game.PlayedAt,
+		).Scan(&later)
+		if err != nil {
+			return nil, err
+		}
+		if later {
+			if err := rebuildProjections(ctx, tx); err != nil {
+				return nil, err
+			}
+			if game, err = getGame(ctx, tx, gameID); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	if err := tx.Commit(ctx); err != nil {
+	if err := recordAudit(ctx, tx, AuditCreate, AuditEntityGame, gameID, nil, game); err != nil {
 		return nil, err
 	}
+	return game, nil
+}
 
-	return &models.Game{ID: gameID, GameType: req.GameType, Players: gamePlayers}, nil
+// teamPlayerIDs returns the players of every team, checking that each team
+// has someone on it and that nobody plays twice.
+func teamPlayerIDs(teams []models.CreateGameTeam) ([]int, error) {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, team := range teams {
+		if len(team.PlayerIDs) == 0 {
+			return nil, fmt.Errorf("each team needs at least one player")
+		}
+		for _, id := range team.PlayerIDs {
+			if seen[id] {
+				return nil, fmt.Errorf("player %d appears more than once", id)
+			}
+			seen[id] = true
+		}
+		ids = append(ids, team.PlayerIDs...)
+	}
+	return ids, nil
 }
 
-func (r *Repository) ListGames(ctx context.Context, limit int) ([]models.Game, error) {
-	rows, err := r.db.Query(ctx,
-		`SELECT g.id, g.game_type, g.created_at, gp.player_id, p.name, gp.team, gp.score, gp.rating_before, gp.rating_after
-		 FROM games g
-		 JOIN game_participants gp ON g.id = gp.game_id
-		 JOIN players p ON gp.player_id = p.id
-		 ORDER BY g.created_at DESC, g.id, gp.team
-		 LIMIT $1`, limit*10,
+func (r *Repository) ListGames(ctx context.Context, filter models.GameFilter) (*models.GamePage, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, "g.organization_id = "+arg(organizationID(ctx)))
+	if filter.Cursor != "" {
+		playedAt, id, err := decodeGameCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(g.played_at, g.id) < (%s, %s)", arg(playedAt), arg(id)))
+	}
+	if filter.PlayerID != nil {
+		player := arg(*filter.PlayerID)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM game_participants x WHERE x.game_id = g.id AND x.player_id = %s)", player))
+
+		if filter.TeammateID != nil {
+			conditions = append(conditions, fmt.Sprintf(
+				`EXISTS (SELECT 1 FROM game_participants a JOIN game_participants b ON a.game_id = b.game_id AND a.team = b.team
+				 WHERE a.game_id = g.id AND a.player_id = %s AND b.player_id = %s)`, player, arg(*filter.TeammateID)))
+		}
+		if filter.OpponentID != nil {
+			conditions = append(conditions, fmt.Sprintf(
+				`EXISTS (SELECT 1 FROM game_participants a JOIN game_participants b ON a.game_id = b.game_id AND a.team != b.team
+				 WHERE a.game_id = g.id AND a.player_id = %s AND b.player_id = %s)`, player, arg(*filter.OpponentID)))
+		}
+	}
+	if filter.GameType != "" {
+		conditions = append(conditions, "g.game_type = "+arg(filter.GameType))
+	}
+	if filter.TableID != nil {
+		conditions = append(conditions, "g.table_id = "+arg(*filter.TableID))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "g.played_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "g.played_at < "+arg(*filter.To))
+	}
+	if filter.Score != nil {
+		high, low := arg(filter.Score[0]), arg(filter.Score[1])
+		conditions = append(conditions, fmt.Sprintf(
+			`EXISTS (SELECT 1 FROM game_participants s1 JOIN game_participants s2 ON s1.game_id = s2.game_id AND s1.team = 1 AND s2.team = 2
+			 WHERE s1.game_id = g.id AND ((s1.score = %[1]s AND s2.score = %[2]s) OR (s1.score = %[2]s AND s2.score = %[1]s)))`, high, low))
+	}
+
+	if filter.Disputed {
+		conditions = append(conditions, disputedColumn("g.id"))
+	}
+
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	// Fetch one extra game to learn whether another page follows.
+	limit := arg(filter.Limit + 1)
+	rows, err := r.db.Query(ctx, fmt.Sprintf(
+		`WITH page AS (
+			SELECT g.id, g.game_type, g.table_id, g.version, g.played_at, g.created_at, g.submitted_by
+			FROM games g
+			%s
+			ORDER BY g.played_at DESC, g.id DESC
+			LIMIT %s
+		)
+		SELECT page.id, page.game_type, page.table_id, page.version, page.played_at, page.created_at, page.submitted_by, u.username, %s, %s, gp.player_id, p.name, gp.team, COALESCE(gp.side, ''), gp.score, gp.rating_before, gp.rating_after
+		FROM page
+		LEFT JOIN users u ON page.submitted_by = u.id
+		LEFT JOIN game_participants gp ON page.id = gp.game_id
+		LEFT JOIN players p ON gp.player_id = p.id
+		ORDER BY page.played_at DESC, page.id DESC, gp.team, gp.player_id`, where, limit, disputedColumn("page.id"), goalsColumn("page.id")),
+		args...,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	gamesMap := make(map[int]*models.Game)
-	var gameIDs []int
-
+	games := []models.Game{}
 	for rows.Next() {
-		var gameID int
-		var gameType string
-		var createdAt interface{}
-		var gp models.GamePlayer
-
-		err := rows.Scan(&gameID, &gameType, &createdAt, &gp.PlayerID, &gp.PlayerName, &gp.Team, &gp.Score, &gp.RatingBefore, &gp.RatingAfter)
+		var game models.Game
+		var playerID, team, score, ratingBefore, ratingAfter *int
+		var playerName, side *string
+		var submitterID *int
+		var submitterName *string
+		var goals []byte
+
+		err := rows.Scan(&game.ID, &game.GameType, &game.TableID, &game.Version, &game.PlayedAt, &game.CreatedAt, &submitterID, &submitterName, &game.Disputed, &goals, &playerID, &playerName, &team, &side, &score, &ratingBefore, &ratingAfter)
 		if err != nil {
 			return nil, err
 		}
 
-		if _, exists := gamesMap[gameID]; !exists {
-			gamesMap[gameID] = &models.Game{ID: gameID, GameType: gameType, Players: []models.GamePlayer{}}
-			gameIDs = append(gameIDs, gameID)
+		if len(games) == 0 || games[len(games)-1].ID != game.ID {
+			game.Players = []models.GamePlayer{}
+			game.SubmittedBy = gameSubmitter(submitterID, submitterName)
+			if game.Goals, err = decodeGoals(goals); err != nil {
+				return nil, err
+			}
+			games = append(games, game)
+		}
+		if playerID != nil {
+			last := &games[len(games)-1]
+			last.Players = append(last.Players, models.GamePlayer{
+				PlayerID:     *playerID,
+				PlayerName:   *playerName,
+				Team:         *team,
+				Side:         *side,
+				Score:        *score,
+				RatingBefore: *ratingBefore,
+				RatingAfter:  *ratingAfter,
+			})
 		}
-		gamesMap[gameID].Players = append(gamesMap[gameID].Players, gp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	games := make([]models.Game, 0, len(gameIDs))
-	for _, id := range gameIDs {
-		if len(games) >= limit {
-			break
-		}
-		games = append(games, *gamesMap[id])
+	page := &models.GamePage{Games: games}
+	if len(games) > filter.Limit {
+		page.Games = games[:filter.Limit]
+		last := page.Games[len(page.Games)-1]
+		page.NextCursor = encodeGameCursor(last.PlayedAt, last.ID)
 	}
-	return games, rows.Err()
+	return page, nil
 }
 
 func (r *Repository) GetLeaderboard(ctx context.Context) ([]models.LeaderboardEntry, error) {
 	rows, err := r.db.Query(ctx,
-		`SELECT p.id, p.name, p.rating, p.games_played, p.created_at,
+		`SELECT p.id, p.name, p.rating, p.games_played, p.version, p.created_at,
 		        COUNT(CASE WHEN gp.rating_after > gp.rating_before THEN 1 END) as wins,
 		        COUNT(CASE WHEN gp.rating_after < gp.rating_before THEN 1 END) as losses
 		 FROM players p
 		 LEFT JOIN game_participants gp ON p.id = gp.player_id
+		 WHERE p.organization_id = $1
 		 GROUP BY p.id
 		 ORDER BY p.rating DESC`,
+		organizationID(ctx),
 	)
 	if err != nil {
 		return nil, err
 	}
+	return scanLeaderboard(rows)
+}
+
+// GetLeaderboardAsOf rebuilds the standings from game history as they stood
+// just before asOf: each player's rating is the rating_after of their last
+// game before that moment, and only games before it count towards wins and
+// losses. Players created later are left out unless they have games before
+// asOf, which happens when history is backfilled.
+func (r *Repository) GetLeaderboardAsOf(ctx context.Context, asOf time.Time) ([]models.LeaderboardEntry, error) {
+	rows, err := r.db.Query(ctx,
+		`WITH history AS (
+			SELECT gp.player_id, gp.rating_before, gp.rating_after,
+			       ROW_NUMBER() OVER (PARTITION BY gp.player_id ORDER BY g.played_at DESC, g.id DESC) as rn
+			FROM game_participants gp
+			JOIN games g ON gp.game_id = g.id
+			WHERE g.organization_id = $3 AND g.played_at < $1
+		)
+		SELECT p.id, p.name,
+		       COALESCE(MAX(h.rating_after) FILTER (WHERE h.rn = 1), $2) as rating,
+		       COUNT(h.player_id) as games_played,
+		       p.version,
+		       p.created_at,
+		       COUNT(CASE WHEN h.rating_after > h.rating_before THEN 1 END) as wins,
+		       COUNT(CASE WHEN h.rating_after < h.rating_before THEN 1 END) as losses
+		FROM players p
+		LEFT JOIN history h ON p.id = h.player_id
+		WHERE p.organization_id = $3
+		GROUP BY p.id
+		HAVING p.created_at < $1 OR COUNT(h.player_id) > 0
+		ORDER BY rating DESC, p.id`,
+		asOf, elo.InitialRating, organizationID(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanLeaderboard(rows)
+}
+
+func scanLeaderboard(rows pgx.Rows) ([]models.LeaderboardEntry, error) {
 	defer rows.Close()
 
 	var entries []models.LeaderboardEntry
 	for rows.Next() {
 		var entry models.LeaderboardEntry
-		err := rows.Scan(&entry.ID, &entry.Name, &entry.Rating, &entry.GamesPlayed, &entry.CreatedAt, &entry.Wins, &entry.Losses)
+		err := rows.Scan(&entry.ID, &entry.Name, &entry.Rating, &entry.GamesPlayed, &entry.Version, &entry.CreatedAt, &entry.Wins, &entry.Losses)
 		if err != nil {
 			return nil, err
 		}
@@ -226,207 +530,249 @@ func (r *Repository) GetLeaderboard(ctx context.Context) ([]models.LeaderboardEn
 	return entries, rows.Err()
 }
 
-func (r *Repository) DeleteGame(ctx context.Context, gameID string) error {
+func (r *Repository) DeleteGame(ctx context.Context, gameID string, expectedVersion *int) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
-	// Get all participants to revert their ratings
-	rows, err := tx.Query(ctx,
-		`SELECT player_id, rating_before FROM game_participants WHERE game_id = $1`,
-		gameID,
-	)
-	if err != nil {
+	if err := lockLedger(ctx, tx); err != nil {
 		return err
 	}
-	defer rows.Close()
 
-	type revert struct {
-		playerID     int
-		ratingBefore int
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return err
 	}
-	var reverts []revert
 
-	for rows.Next() {
-		var r revert
-		if err := rows.Scan(&r.playerID, &r.ratingBefore); err != nil {
-			return err
-		}
-		reverts = append(reverts, r)
+	if err := voidGame(ctx, tx, changes, gameID, expectedVersion); err != nil {
+		return err
 	}
 
-	if len(reverts) == 0 {
+	return r.commit(ctx, tx, changes)
+}
+
+// voidGame deletes a game within tx, which must hold the ledger lock.
+func voidGame(ctx context.Context, tx pgx.Tx, changes *changeSet, gameID interface{}, expectedVersion *int) error {
+	before, err := getGame(ctx, tx, gameID)
+	if err != nil {
 		return fmt.Errorf("game not found")
 	}
+	if err := checkVersion(expectedVersion, before.Version); err != nil {
+		return err
+	}
 
-	// Revert each player's rating and decrement games_played
-	for _, rev := range reverts {
-		_, err = tx.Exec(ctx,
-			`UPDATE players SET rating = $1, games_played = games_played - 1 WHERE id = $2`,
-			rev.ratingBefore, rev.playerID,
-		)
-		if err != nil {
-			return err
-		}
+	if err := appendEvent(ctx, tx, ledger.GameVoided, before.ID, ledger.GameVoidedPayload{GameID: before.ID}); err != nil {
+		return err
 	}
 
-	// Delete the game (cascades to game_participants)
-	_, err = tx.Exec(ctx, `DELETE FROM games WHERE id = $1`, gameID)
-	if err != nil {
+	// Replaying the ledger without the voided game reverts its rating
+	// changes and recomputes every game recorded after it.
+	if err := rebuildProjections(ctx, tx); err != nil {
 		return err
 	}
 
-	return tx.Commit(ctx)
+	if err := recordAudit(ctx, tx, AuditDelete, AuditEntityGame, before.ID, before, nil); err != nil {
+		return err
+	}
+
+	// A dispute about a game that no longer exists has nothing left to
+	// resolve.
+	if err := closeDisputes(ctx, tx, before.ID); err != nil {
+		return err
+	}
+
+	return changes.addGame(ChangeGameDeleted, before)
 }
 
-func (r *Repository) UpdateGame(ctx context.Context, gameID string, team1Score, team2Score int) error {
+// UpdateGame corrects a game's score. The score of a game with a goal
+// timeline comes from its goals, so such games are corrected by replacing
+// the goals instead.
+func (r *Repository) UpdateGame(ctx context.Context, gameID string, team1Score, team2Score int, goals []models.Goal, expectedVersion *int) (*models.Game, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
-	// Get game info
-	var gameType string
-	err = tx.QueryRow(ctx, `SELECT game_type FROM games WHERE id = $1`, gameID).Scan(&gameType)
+	if err := lockLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
 	if err != nil {
-		return fmt.Errorf("game not found")
+		return nil, err
 	}
 
-	// Get all participants with their original ratings
-	rows, err := tx.Query(ctx,
-		`SELECT player_id, team, rating_before FROM game_participants WHERE game_id = $1 ORDER BY team`,
-		gameID,
-	)
+	after, err := correctGame(ctx, tx, changes, gameID, team1Score, team2Score, goals, expectedVersion)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer rows.Close()
 
-	type participant struct {
-		playerID     int
-		team         int
-		ratingBefore int
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
 	}
-	var participants []participant
+	return after, nil
+}
 
-	for rows.Next() {
-		var p participant
-		if err := rows.Scan(&p.playerID, &p.team, &p.ratingBefore); err != nil {
-			return err
-		}
-		participants = append(participants, p)
+// correctGame is UpdateGame within tx, which must hold the ledger lock.
+func correctGame(ctx context.Context, tx pgx.Tx, changes *changeSet, gameID interface{}, team1Score, team2Score int, goals []models.Goal, expectedVersion *int) (*models.Game, error) {
+	before, err := getGame(ctx, tx, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("game not found")
+	}
+	if err := checkVersion(expectedVersion, before.Version); err != nil {
+		return nil, err
 	}
 
-	// Separate by team
-	var team1Players, team2Players []participant
-	for _, p := range participants {
-		if p.team == 1 {
-			team1Players = append(team1Players, p)
-		} else {
-			team2Players = append(team2Players, p)
+	if len(goals) > 0 {
+		teams := []models.CreateGameTeam{{Score: team1Score}, {Score: team2Score}}
+		for _, gp := range before.Players {
+			teams[gp.Team-1].PlayerIDs = append(teams[gp.Team-1].PlayerIDs, gp.PlayerID)
+		}
+		if err := ledger.ScoreGoals(teams, goals); err != nil {
+			return nil, err
 		}
+		team1Score, team2Score = teams[0].Score, teams[1].Score
+	} else if len(before.Goals) > 0 {
+		return nil, fmt.Errorf("%w: this game's score comes from its goals, so correct the goals instead", ledger.ErrInvalidGoals)
 	}
 
-	// Calculate new ratings
-	team1Ratings := make([]int, len(team1Players))
-	for i, p := range team1Players {
-		team1Ratings[i] = p.ratingBefore
+	correction := ledger.GameCorrectedPayload{GameID: before.ID, Team1Score: team1Score, Team2Score: team2Score, Goals: goals}
+	if err := appendEvent(ctx, tx, ledger.GameCorrected, before.ID, correction); err != nil {
+		return nil, err
 	}
 
-	team2Ratings := make([]int, len(team2Players))
-	for i, p := range team2Players {
-		team2Ratings[i] = p.ratingBefore
+	if err := rebuildProjections(ctx, tx); err != nil {
+		return nil, err
 	}
 
-	deltaTeam1, deltaTeam2 := elo.CalculateNewRatings(
-		elo.AverageRating(team1Ratings),
-		elo.AverageRating(team2Ratings),
-		team1Score > team2Score,
-	)
-
-	// Update game_participants with new scores and ratings
-	for _, p := range team1Players {
-		newRating := p.ratingBefore + deltaTeam1
-		_, err = tx.Exec(ctx,
-			`UPDATE game_participants SET score = $1, rating_after = $2 WHERE game_id = $3 AND player_id = $4`,
-			team1Score, newRating, gameID, p.playerID,
-		)
-		if err != nil {
-			return err
-		}
-		// Update player's current rating
-		_, err = tx.Exec(ctx,
-			`UPDATE players SET rating = rating - $1 + $2 WHERE id = $3`,
-			p.ratingBefore+deltaTeam1, newRating, p.playerID,
-		)
-		if err != nil {
-			return err
-		}
+	after, err := getGame(ctx, tx, gameID)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, p := range team2Players {
-		newRating := p.ratingBefore + deltaTeam2
-		_, err = tx.Exec(ctx,
-			`UPDATE game_participants SET score = $1, rating_after = $2 WHERE game_id = $3 AND player_id = $4`,
-			team2Score, newRating, gameID, p.playerID,
-		)
-		if err != nil {
-			return err
-		}
-		// Update player's current rating
-		_, err = tx.Exec(ctx,
-			`UPDATE players SET rating = rating - $1 + $2 WHERE id = $3`,
-			p.ratingBefore+deltaTeam2, newRating, p.playerID,
-		)
-		if err != nil {
-			return err
-		}
+	if err := recordAudit(ctx, tx, AuditUpdate, AuditEntityGame, before.ID, before, after); err != nil {
+		return nil, err
 	}
 
-	return tx.Commit(ctx)
+	if err := changes.addGame(ChangeGameCorrected, after); err != nil {
+		return nil, err
+	}
+	return after, nil
 }
 
-func (r *Repository) DeletePlayer(ctx context.Context, playerID string) error {
-	result, err := r.db.Exec(ctx, `DELETE FROM players WHERE id = $1`, playerID)
+func (r *Repository) DeletePlayer(ctx context.Context, playerID string, expectedVersion *int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := joinLedger(ctx, tx); err != nil {
+		return err
+	}
+
+	changes, err := trackChanges(ctx, tx)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
+	before, err := lockPlayer(ctx, tx, playerID)
+	if err != nil {
 		return fmt.Errorf("player not found")
 	}
+	if err := checkVersion(expectedVersion, before.Version); err != nil {
+		return err
+	}
 
-	return nil
-}
+	_, err = tx.Exec(ctx, `DELETE FROM players WHERE id = $1 AND organization_id = $2`, playerID, organizationID(ctx))
+	if err != nil {
+		return err
+	}
 
-func (r *Repository) UpdatePlayer(ctx context.Context, playerID string, name string) error {
-	result, err := r.db.Exec(ctx, `UPDATE players SET name = $1 WHERE id = $2`, name, playerID)
+	// Games the player is waiting on can no longer be recorded.
+	_, err = tx.Exec(ctx,
+		`UPDATE pending_games SET status = 'rejected', resolved_by = $3, resolved_at = NOW()
+		 WHERE organization_id = $1 AND status = 'pending' AND $2 = ANY(player_ids)`,
+		organizationID(ctx), before.ID, actorFromContext(ctx))
 	if err != nil {
 		return err
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("player not found")
+	if err := appendEvent(ctx, tx, ledger.PlayerDeleted, before.ID, ledger.PlayerDeletedPayload{PlayerID: before.ID}); err != nil {
+		return err
 	}
 
-	return nil
+	if err := recordAudit(ctx, tx, AuditDelete, AuditEntityPlayer, before.ID, before, nil); err != nil {
+		return err
+	}
+
+	return r.commit(ctx, tx, changes)
 }
 
-func (r *Repository) GetPlayerByID(ctx context.Context, playerID int) (*models.Player, error) {
-	var player models.Player
-	err := r.db.QueryRow(ctx, `SELECT id, name, rating, games_played, created_at FROM players WHERE id = $1`, playerID).
-		Scan(&player.ID, &player.Name, &player.Rating, &player.GamesPlayed, &player.CreatedAt)
+func (r *Repository) UpdatePlayer(ctx context.Context, playerID string, name string, expectedVersion *int) (*models.Player, error) {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &player, nil
+	defer tx.Rollback(ctx)
+
+	if err := joinLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	before, err := lockPlayer(ctx, tx, playerID)
+	if err != nil {
+		return nil, fmt.Errorf("player not found")
+	}
+	if err := checkVersion(expectedVersion, before.Version); err != nil {
+		return nil, err
+	}
+
+	after := *before
+	err = tx.QueryRow(ctx,
+		`UPDATE players SET name = $1, version = version + 1 WHERE id = $2 AND organization_id = $3 RETURNING name, version`,
+		name, playerID, organizationID(ctx),
+	).
+		Scan(&after.Name, &after.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := appendEvent(ctx, tx, ledger.PlayerRenamed, before.ID, ledger.PlayerRenamedPayload{PlayerID: before.ID, Name: name}); err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditUpdate, AuditEntityPlayer, before.ID, before, &after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &after, nil
 }
 
-func (r *Repository) GetPlayerStats(ctx context.Context, playerID int) (*models.PlayerStats, error) {
+func (r *Repository) GetPlayerByID(ctx context.Context, playerID int) (*models.Player, error) {
+	return getPlayer(ctx, r.db, playerID)
+}
+
+func (r *Repository) GetGameByID(ctx context.Context, gameID int) (*models.Game, error) {
+	return getGame(ctx, r.db, gameID)
+}
+
+func checkVersion(expected *int, actual int) error {
+	if expected != nil && *expected != actual {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// GetPlayerStats summarises a player's games, optionally only those played on
+// one table.
+func (r *Repository) GetPlayerStats(ctx context.Context, playerID int, tableID *int) (*models.PlayerStats, error) {
 	var stats models.PlayerStats
 
 	// Get basic stats
@@ -436,7 +782,8 @@ func (r *Repository) GetPlayerStats(ctx context.Context, playerID int) (*models.
 			COALESCE(AVG(CASE WHEN gp.rating_after > gp.rating_before THEN 1.0 ELSE 0.0 END), 0) as win_rate,
 			COALESCE(AVG(gp.rating_after - gp.rating_before), 0) as avg_rating_change
 		FROM game_participants gp
-		WHERE gp.player_id = $1`, playerID).
+		JOIN games g ON gp.game_id = g.id
+		WHERE gp.player_id = $1 AND g.organization_id = $2 AND ($3::int IS NULL OR g.table_id = $3)`, playerID, organizationID(ctx), tableID).
 		Scan(&stats.TotalGames, &stats.WinRate, &stats.AvgRatingChange)
 	if err != nil {
 		return nil, err
@@ -447,7 +794,8 @@ func (r *Repository) GetPlayerStats(ctx context.Context, playerID int) (*models.
 		SELECT
 			COALESCE(MAX(gp.rating_after), 1500) as peak_rating
 		FROM game_participants gp
-		WHERE gp.player_id = $1`, playerID).
+		JOIN games g ON gp.game_id = g.id
+		WHERE gp.player_id = $1 AND g.organization_id = $2 AND ($3::int IS NULL OR g.table_id = $3)`, playerID, organizationID(ctx), tableID).
 		Scan(&stats.PeakRating)
 	if err != nil {
 		return nil, err
@@ -458,9 +806,9 @@ func (r *Repository) GetPlayerStats(ctx context.Context, playerID int) (*models.
 		SELECT gp.rating_after > gp.rating_before as won
 		FROM game_participants gp
 		JOIN games g ON gp.game_id = g.id
-		WHERE gp.player_id = $1
-		ORDER BY g.created_at DESC
-		LIMIT 20`, playerID)
+		WHERE gp.player_id = $1 AND g.organization_id = $2 AND ($3::int IS NULL OR g.table_id = $3)
+		ORDER BY g.played_at DESC, g.id DESC
+		LIMIT 20`, playerID, organizationID(ctx), tableID)
 	if err != nil {
 		return nil, err
 	}
@@ -514,23 +862,28 @@ func (r *Repository) GetPlayerStats(ctx context.Context, playerID int) (*models.
 		stats.LongestLoseStreak = maxLose
 	}
 
+	if err := goalStats(ctx, r.db, playerID, tableID, &stats); err != nil {
+		return nil, err
+	}
+
 	return &stats, nil
 }
 
 func (r *Repository) GetPlayerHeadToHead(ctx context.Context, playerID int) ([]models.HeadToHead, error) {
 	rows, err := r.db.Query(ctx, `
 		WITH player_games AS (
-			SELECT DISTINCT g.id, g.created_at, gp1.team as player_team
+			SELECT DISTINCT g.id, g.played_at, gp1.team as player_team
 			FROM games g
 			JOIN game_participants gp1 ON g.id = gp1.game_id AND gp1.player_id = $1
+			WHERE g.organization_id = $2
 		),
 		opponent_results AS (
 			SELECT
 				gp2.player_id as opponent_id,
 				p2.name as opponent_name,
 				gp1.rating_after > gp1.rating_before as player_won,
-				g.created_at,
-				ROW_NUMBER() OVER (PARTITION BY gp2.player_id ORDER BY g.created_at DESC) as rn
+				g.played_at,
+				ROW_NUMBER() OVER (PARTITION BY gp2.player_id ORDER BY g.played_at DESC, g.id DESC) as rn
 			FROM player_games pg
 			JOIN games g ON pg.id = g.id
 			JOIN game_participants gp1 ON g.id = gp1.game_id AND gp1.player_id = $1
@@ -550,7 +903,7 @@ func (r *Repository) GetPlayerHeadToHead(ctx context.Context, playerID int) ([]m
 		FROM opponent_results main
 		GROUP BY opponent_id, opponent_name
 		HAVING COUNT(*) > 0
-		ORDER BY total_games DESC`, playerID)
+		ORDER BY total_games DESC`, playerID, organizationID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -573,11 +926,11 @@ func (r *Repository) GetPlayerHeadToHead(ctx context.Context, playerID int) ([]m
 
 func (r *Repository) GetPlayerRatingHistory(ctx context.Context, playerID int) ([]models.RatingHistoryPoint, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT g.created_at, gp.rating_after, g.id
+		SELECT g.played_at, gp.rating_after, g.id
 		FROM game_participants gp
 		JOIN games g ON gp.game_id = g.id
-		WHERE gp.player_id = $1
-		ORDER BY g.created_at ASC`, playerID)
+		WHERE gp.player_id = $1 AND g.organization_id = $2
+		ORDER BY g.played_at ASC, g.id ASC`, playerID, organizationID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -600,11 +953,12 @@ func (r *Repository) GetPlayerRecentGames(ctx context.Context, playerID int) ([]
 		WITH player_games AS (
 			SELECT
 				g.id,
-				g.created_at,
+				g.played_at,
 				g.game_type,
 				gp1.rating_after > gp1.rating_before as won
 			FROM games g
 			JOIN game_participants gp1 ON g.id = gp1.game_id AND gp1.player_id = $1
+			WHERE g.organization_id = $2
 		),
 		opponents AS (
 			SELECT
@@ -617,14 +971,14 @@ func (r *Repository) GetPlayerRecentGames(ctx context.Context, playerID int) ([]
 		)
 		SELECT
 			pg.id,
-			pg.created_at,
+			pg.played_at,
 			pg.won,
 			COALESCE(o.opponent_names, 'Unknown') as opponent,
 			pg.game_type
 		FROM player_games pg
 		LEFT JOIN opponents o ON pg.id = o.id
-		ORDER BY pg.created_at DESC
-		LIMIT 10`, playerID)
+		ORDER BY pg.played_at DESC, pg.id DESC
+		LIMIT 10`, playerID, organizationID(ctx))
 	if err != nil {
 		return nil, err
 	}
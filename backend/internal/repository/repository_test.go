@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/testdb"
+)
+
+// newTestRepository returns a repository over a fresh test database and a
+// context scoped to its default organization.
+func newTestRepository(t *testing.T) (*Repository, context.Context) {
+	t.Helper()
+	repo := New(testdb.New(t))
+	org, err := repo.GetOrganizationBySlug(context.Background(), DefaultOrganization)
+	if err != nil {
+		t.Fatalf("loading default organization: %v", err)
+	}
+	return repo, WithOrganization(context.Background(), org)
+}
+
+func createPlayers(t *testing.T, ctx context.Context, repo *Repository, n int) []int {
+	t.Helper()
+	ids := make([]int, n)
+	for i := range ids {
+		player, err := repo.CreatePlayer(ctx, fmt.Sprintf("Player %d", i+1))
+		if err != nil {
+			t.Fatalf("creating player: %v", err)
+		}
+		ids[i] = player.ID
+	}
+	return ids
+}
+
+func singlesGame(winner, loser, loserScore int) models.CreateGameRequest {
+	return models.CreateGameRequest{
+		GameType: "singles",
+		Teams: []models.CreateGameTeam{
+			{PlayerIDs: []int{winner}, Score: 10},
+			{PlayerIDs: []int{loser}, Score: loserScore},
+		},
+	}
+}
+
+func standings(t *testing.T, ctx context.Context, repo *Repository) map[int]models.LeaderboardEntry {
+	t.Helper()
+	players, err := repo.ListPlayers(ctx)
+	if err != nil {
+		t.Fatalf("listing players: %v", err)
+	}
+	byID := make(map[int]models.LeaderboardEntry, len(players))
+	for _, p := range players {
+		byID[p.ID] = p
+	}
+	return byID
+}
+
+// TestConcurrentGamesMatchReplay records games that share players in
+// parallel and checks that replaying the ledger, which rates games one at a
+// time in the order they were played, gives every player the same rating.
+func TestConcurrentGamesMatchReplay(t *testing.T) {
+	repo, ctx := newTestRepository(t)
+	players := createPlayers(t, ctx, repo, 6)
+
+	const games = 60
+	start := make(chan struct{})
+	errs := make(chan error, games)
+	var wg sync.WaitGroup
+	for i := 0; i < games; i++ {
+		n := len(players)
+		winner := players[i%n]
+		loser := players[(i+1+(i/n)%(n-1))%n]
+		wg.Add(1)
+		go func(req models.CreateGameRequest) {
+			defer wg.Done()
+			<-start
+			_, err := repo.CreateGame(ctx, req)
+			errs <- err
+		}(singlesGame(winner, loser, i%10))
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("recording game: %v", err)
+		}
+	}
+
+	live := standings(t, ctx, repo)
+	if err := repo.RebuildProjections(ctx); err != nil {
+		t.Fatalf("replaying ledger: %v", err)
+	}
+	replayed := standings(t, ctx, repo)
+
+	total := 0
+	for _, id := range players {
+		if live[id].Rating != replayed[id].Rating {
+			t.Errorf("player %d: rated %d live but %d on replay", id, live[id].Rating, replayed[id].Rating)
+		}
+		if live[id].GamesPlayed != replayed[id].GamesPlayed {
+			t.Errorf("player %d: played %d games live but %d on replay", id, live[id].GamesPlayed, replayed[id].GamesPlayed)
+		}
+		total += live[id].GamesPlayed
+	}
+	if total != 2*games {
+		t.Errorf("players played %d games between them, want %d", total, 2*games)
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/elo"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/ledger"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+// confidenceZ is the normal quantile for the 95% intervals in side reports.
+const confidenceZ = 1.96
+
+// sideRecords tallies the results of the organization's games that have
+// sides recorded, optionally only those on one table.
+func sideRecords(ctx context.Context, q querier, tableID *int) (ledger.SideRecords, error) {
+	rows, err := q.Query(ctx, `
+		WITH teams AS (
+			SELECT g.id, gp.team, MAX(gp.side) AS side, MAX(gp.score) AS score
+			FROM games g
+			JOIN game_participants gp ON gp.game_id = g.id
+			WHERE g.organization_id = $1 AND ($2::int IS NULL OR g.table_id = $2)
+			GROUP BY g.id, gp.team
+		)
+		SELECT t1.side, t2.side, t1.score > t2.score
+		FROM teams t1
+		JOIN teams t2 ON t1.id = t2.id AND t1.team = 1 AND t2.team = 2
+		WHERE t1.side IS NOT NULL AND t2.side IS NOT NULL`,
+		organizationID(ctx), tableID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(ledger.SideRecords)
+	for rows.Next() {
+		var side1, side2 string
+		var team1Won bool
+		if err := rows.Scan(&side1, &side2, &team1Won); err != nil {
+			return nil, err
+		}
+		records.Record(side1, side2, team1Won)
+	}
+	return records, rows.Err()
+}
+
+// GetSideAdvantage reports how each pair of sides has fared against each
+// other, optionally only on one table. The applied offset is always learned
+// from every game, as the rating engine does.
+func (r *Repository) GetSideAdvantage(ctx context.Context, tableID *int) ([]models.SideAdvantage, error) {
+	all, err := sideRecords(ctx, r.db, nil)
+	if err != nil {
+		return nil, err
+	}
+	records := all
+	if tableID != nil {
+		if _, err := getTable(ctx, r.db, *tableID); err != nil {
+			return nil, err
+		}
+		if records, err = sideRecords(ctx, r.db, tableID); err != nil {
+			return nil, err
+		}
+	}
+
+	report := []models.SideAdvantage{}
+	for pair, rec := range records {
+		winRate := float64(rec.Wins) / float64(rec.Games)
+		low, high := elo.WinRateInterval(rec.Wins, rec.Games, confidenceZ)
+		report = append(report, models.SideAdvantage{
+			Side:             pair[0],
+			OpponentSide:     pair[1],
+			Games:            rec.Games,
+			Wins:             rec.Wins,
+			WinRate:          winRate,
+			WinRateLow:       low,
+			WinRateHigh:      high,
+			RatingOffset:     elo.RatingOffset(winRate),
+			RatingOffsetLow:  elo.RatingOffset(low),
+			RatingOffsetHigh: elo.RatingOffset(high),
+			AppliedOffset:    all.Advantage(pair[0], pair[1]),
+		})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Side != report[j].Side {
+			return report[i].Side < report[j].Side
+		}
+		return report[i].OpponentSide < report[j].OpponentSide
+	})
+	return report, nil
+}
+
+// sideValue stores a missing side as NULL.
+func sideValue(side string) *string {
+	if side == "" {
+		return nil
+	}
+	return &side
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+)
+
+var (
+	ErrTableNotFound = errors.New("table not found")
+	ErrTableExists   = errors.New("a table with that name already exists")
+)
+
+const tableColumns = `id, name, location, k_multiplier, version, created_at`
+
+func scanTable(row pgx.Row) (*models.Table, error) {
+	var t models.Table
+	err := row.Scan(&t.ID, &t.Name, &t.Location, &t.KMultiplier, &t.Version, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTableNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func getTable(ctx context.Context, q querier, tableID int) (*models.Table, error) {
+	return scanTable(q.QueryRow(ctx,
+		`SELECT `+tableColumns+` FROM tables WHERE id = $1 AND organization_id = $2`, tableID, organizationID(ctx)))
+}
+
+// tableMultipliers maps each of the organization's tables to the factor its
+// games' K factor is scaled by.
+func tableMultipliers(ctx context.Context, q querier) (map[int]float64, error) {
+	rows, err := q.Query(ctx, `SELECT id, k_multiplier FROM tables WHERE organization_id = $1`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	multipliers := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var multiplier float64
+		if err := rows.Scan(&id, &multiplier); err != nil {
+			return nil, err
+		}
+		multipliers[id] = multiplier
+	}
+	return multipliers, rows.Err()
+}
+
+func (r *Repository) ListTables(ctx context.Context) ([]models.Table, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+tableColumns+` FROM tables WHERE organization_id = $1 ORDER BY name`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []models.Table{}
+	for rows.Next() {
+		t, err := scanTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, *t)
+	}
+	return tables, rows.Err()
+}
+
+func (r *Repository) GetTableByID(ctx context.Context, tableID int) (*models.Table, error) {
+	return getTable(ctx, r.db, tableID)
+}
+
+func (r *Repository) CreateTable(ctx context.Context, req models.TableRequest) (*models.Table, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	table, err := scanTable(tx.QueryRow(ctx,
+		`INSERT INTO tables (organization_id, name, location, k_multiplier) VALUES ($1, $2, $3, COALESCE($4, 1))
+		 RETURNING `+tableColumns,
+		organizationID(ctx), req.Name, req.Location, req.KMultiplier,
+	))
+	if isUniqueViolation(err) {
+		return nil, ErrTableExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditCreate, AuditEntityTable, table.ID, nil, table); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// UpdateTable renames or moves a table and changes its rating adjustment.
+// Games are always rated with the current multiplier, so changing it replays
+// the ledger.
+func (r *Repository) UpdateTable(ctx context.Context, tableID int, req models.TableRequest, expectedVersion *int) (*models.Table, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockLedger(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	changes, err := trackChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := scanTable(tx.QueryRow(ctx,
+		`SELECT `+tableColumns+` FROM tables WHERE id = $1 AND organization_id = $2 FOR UPDATE`, tableID, organizationID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(expectedVersion, before.Version); err != nil {
+		return nil, err
+	}
+
+	after, err := scanTable(tx.QueryRow(ctx,
+		`UPDATE tables SET name = $2, location = $3, k_multiplier = COALESCE($4, k_multiplier), version = version + 1
+		 WHERE id = $1
+		 RETURNING `+tableColumns,
+		before.ID, req.Name, req.Location, req.KMultiplier,
+	))
+	if isUniqueViolation(err) {
+		return nil, ErrTableExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if after.KMultiplier != before.KMultiplier {
+		if err := rebuildProjections(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordAudit(ctx, tx, AuditUpdate, AuditEntityTable, after.ID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := r.commit(ctx, tx, changes); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// GetTableStats summarises the games played on a table. Side win rates only
+// count games where sides were recorded.
+func (r *Repository) GetTableStats(ctx context.Context, tableID int) (*models.TableStats, error) {
+	if _, err := getTable(ctx, r.db, tableID); err != nil {
+		return nil, err
+	}
+
+	stats := models.TableStats{TableID: tableID, SideWinRates: map[string]float64{}}
+	err := r.db.QueryRow(ctx, `
+		WITH scores AS (
+			SELECT g.id,
+			       MAX(gp.score) FILTER (WHERE gp.team = 1) AS team1,
+			       MAX(gp.score) FILTER (WHERE gp.team = 2) AS team2
+			FROM games g
+			JOIN game_participants gp ON gp.game_id = g.id
+			WHERE g.table_id = $1 AND g.organization_id = $2
+			GROUP BY g.id
+		)
+		SELECT COUNT(*), COALESCE(AVG(team1 + team2), 0)
+		FROM scores`, tableID, organizationID(ctx)).
+		Scan(&stats.GamesPlayed, &stats.AverageGoals)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := sideRecords(ctx, r.db, &tableID)
+	if err != nil {
+		return nil, err
+	}
+	wins := make(map[string]int)
+	games := make(map[string]int)
+	for pair, rec := range records {
+		wins[pair[0]] += rec.Wins
+		wins[pair[1]] += rec.Games - rec.Wins
+		games[pair[0]] += rec.Games
+		games[pair[1]] += rec.Games
+	}
+	for side, n := range games {
+		stats.SideWinRates[side] = float64(wins[side]) / float64(n)
+	}
+	return &stats, nil
+}
+
+// tableMultiplier returns the K multiplier of a table in the organization,
+// or 1 when no table is given.
+func tableMultiplier(ctx context.Context, q querier, tableID *int) (float64, error) {
+	if tableID == nil {
+		return 1, nil
+	}
+	table, err := getTable(ctx, q, *tableID)
+	if errors.Is(err, ErrTableNotFound) {
+		return 0, fmt.Errorf("table %d not found", *tableID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return table.KMultiplier, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryTracer logs every query at debug level, with how long it took and
+// why it failed, if it did. Set it as the pool's tracer to use it.
+type QueryTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql   string
+	start time.Time
+}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return ctx
+	}
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: data.SQL, start: time.Now()})
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	args := []any{
+		"sql", strings.Join(strings.Fields(q.sql), " "),
+		"duration_ms", float64(time.Since(q.start).Microseconds()) / 1000,
+		"rows", data.CommandTag.RowsAffected(),
+	}
+	if data.Err != nil {
+		args = append(args, "error", data.Err)
+	}
+	slog.DebugContext(ctx, "Query", args...)
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("a user with that username already exists")
+	ErrPlayerLinked       = errors.New("player is already linked to another user")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrSessionNotFound    = errors.New("session not found")
+)
+
+const userColumns = `id, organization_id, username, role, player_id, created_at`
+
+// dummyPasswordHash is compared against when a username is unknown, so
+// logging in takes as long whether or not the user exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+type userKey struct{}
+
+// WithUser attaches the signed-in user making the request to ctx, so the
+// games they record can say who submitted them.
+func WithUser(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+func userIDFromContext(ctx context.Context) *int {
+	if id, ok := ctx.Value(userKey{}).(int); ok {
+		return &id
+	}
+	return nil
+}
+
+func scanUser(row pgx.Row, extra ...interface{}) (*models.User, error) {
+	var u models.User
+	dest := append([]interface{}{&u.ID, &u.OrganizationID, &u.Username, &u.Role, &u.PlayerID, &u.CreatedAt}, extra...)
+	err := row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// userConflict translates a violated unique constraint on users into the
+// error describing it.
+func userConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		switch pgErr.ConstraintName {
+		case "users_username_key":
+			return ErrUserExists
+		case "users_player_id_key":
+			return ErrPlayerLinked
+		}
+	}
+	return err
+}
+
+func (r *Repository) ListUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+userColumns+` FROM users WHERE organization_id = $1 ORDER BY username`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (r *Repository) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = $1 AND organization_id = $2`, userID, organizationID(ctx)))
+}
+
+func (r *Repository) CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := scanUser(tx.QueryRow(ctx,
+		`INSERT INTO users (organization_id, username, password_hash, role, player_id) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+userColumns,
+		organizationID(ctx), req.Username, string(hash), req.Role, req.PlayerID,
+	))
+	if err != nil {
+		return nil, userConflict(err)
+	}
+
+	if err := recordAudit(ctx, tx, AuditCreate, AuditEntityUser, user.ID, nil, user); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, userID int, req models.UpdateUserRequest) (*models.User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanUser(tx.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = $1 AND organization_id = $2 FOR UPDATE`, userID, organizationID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := scanUser(tx.QueryRow(ctx,
+		`UPDATE users SET role = $1, player_id = $2 WHERE id = $3 RETURNING `+userColumns, req.Role, req.PlayerID, userID))
+	if err != nil {
+		return nil, userConflict(err)
+	}
+
+	if err := recordAudit(ctx, tx, AuditUpdate, AuditEntityUser, userID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// DeleteUser removes a user and signs them out. Games they submitted are
+// kept, no longer saying who submitted them.
+func (r *Repository) DeleteUser(ctx context.Context, userID int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanUser(tx.QueryRow(ctx,
+		`DELETE FROM users WHERE id = $1 AND organization_id = $2 RETURNING `+userColumns, userID, organizationID(ctx)))
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, AuditDelete, AuditEntityUser, before.ID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Authenticate checks a username and password within the organization.
+func (r *Repository) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	var hash string
+	user, err := scanUser(r.db.QueryRow(ctx,
+		`SELECT `+userColumns+`, password_hash FROM users WHERE username = $1 AND organization_id = $2`,
+		username, organizationID(ctx)), &hash)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ChangePassword replaces a user's password after checking their current
+// one, and signs them out everywhere else by ending their other sessions.
+func (r *Repository) ChangePassword(ctx context.Context, userID int, current, next, keepSession string) error {
+	var hash string
+	err := r.db.QueryRow(ctx, `SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+
+	newHash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, string(newHash), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM sessions WHERE user_id = $1 AND token_hash != $2`, userID, hashToken(keepSession)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CreateSession signs a user in, returning the token that identifies the
+// session. As with API keys, only a hash of the token is stored.
+func (r *Repository) CreateSession(ctx context.Context, userID int, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM sessions WHERE user_id = $1 AND expires_at < NOW()`, userID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO sessions (token_hash, user_id, expires_at) VALUES ($1, $2, NOW() + $3::interval)`,
+		hashToken(token), userID, ttl.String()); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SessionUser returns the user signed in with token, in whichever
+// organization they belong to, if the session has not expired.
+func (r *Repository) SessionUser(ctx context.Context, token string) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRow(ctx,
+		`SELECT u.id, u.organization_id, u.username, u.role, u.player_id, u.created_at
+		 FROM sessions s
+		 JOIN users u ON u.id = s.user_id
+		 WHERE s.token_hash = $1 AND s.expires_at > NOW()`, hashToken(token)))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	return user, err
+}
+
+func (r *Repository) DeleteSession(ctx context.Context, token string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM sessions WHERE token_hash = $1`, hashToken(token))
+	return err
+}
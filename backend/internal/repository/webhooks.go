@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/models"
+	"github.com/sassoonkuyumcian/foosball-elo/internal/stream"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// WebhookEvents are the changes webhooks can be sent.
+var WebhookEvents = []string{ChangeGameRecorded, ChangeGameDeleted, ChangeLeaderChanged}
+
+// Delivery states. Pending deliveries are retried until they are delivered
+// or the worker gives up on them.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const webhookColumns = `id, url, events, created_at`
+
+const deliveryColumns = `d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.last_status_code, d.last_error, d.redelivery_of, d.created_at, d.delivered_at`
+
+// webhookPayload is the body every webhook receives.
+type webhookPayload struct {
+	Event        string          `json:"event"`
+	Organization string          `json:"organization"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Data         json.RawMessage `json:"data"`
+}
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	var wh models.Webhook
+	err := row.Scan(&wh.ID, &wh.URL, &wh.Events, &wh.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wh, nil
+}
+
+func scanDelivery(row pgx.Row, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	dest := append([]interface{}{&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.RedeliveryOf, &d.CreatedAt, &d.DeliveredAt}, extra...)
+	err := row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *Repository) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE organization_id = $1 ORDER BY id`, organizationID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *wh)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *Repository) GetWebhook(ctx context.Context, webhookID int) (*models.Webhook, error) {
+	return scanWebhook(r.db.QueryRow(ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 AND organization_id = $2`, webhookID, organizationID(ctx)))
+}
+
+// CreateWebhook registers a URL with a freshly generated signing secret,
+// which is returned this once.
+func (r *Repository) CreateWebhook(ctx context.Context, req models.WebhookRequest) (*models.Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	wh, err := scanWebhook(tx.QueryRow(ctx,
+		`INSERT INTO webhooks (organization_id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING `+webhookColumns,
+		organizationID(ctx), req.URL, hex.EncodeToString(secret), req.Events,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditCreate, AuditEntityWebhook, wh.ID, nil, wh); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	wh.Secret = hex.EncodeToString(secret)
+	return wh, nil
+}
+
+// DeleteWebhook removes a webhook along with its delivery log.
+func (r *Repository) DeleteWebhook(ctx context.Context, webhookID int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanWebhook(tx.QueryRow(ctx,
+		`DELETE FROM webhooks WHERE id = $1 AND organization_id = $2 RETURNING `+webhookColumns, webhookID, organizationID(ctx)))
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, AuditDelete, AuditEntityWebhook, before.ID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ListDeliveries returns a webhook's most recent deliveries, newest first.
+func (r *Repository) ListDeliveries(ctx context.Context, webhookID, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := r.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.id DESC LIMIT $2`,
+		webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues the payload of an earlier delivery to be sent again as a
+// new delivery, leaving the original in the log as it was.
+func (r *Repository) Redeliver(ctx context.Context, webhookID int, deliveryID int64) (*models.WebhookDelivery, error) {
+	return scanDelivery(r.db.QueryRow(ctx,
+		`INSERT INTO webhook_deliveries AS d (webhook_id, event, payload, redelivery_of)
+		 SELECT o.webhook_id, o.event, o.payload, o.id
+		 FROM webhook_deliveries o
+		 JOIN webhooks w ON w.id = o.webhook_id
+		 WHERE o.id = $1 AND o.webhook_id = $2 AND w.organization_id = $3
+		 RETURNING `+deliveryColumns,
+		deliveryID, webhookID, organizationID(ctx)))
+}
+
+// enqueueWebhooks queues a delivery of each message that webhooks can be
+// sent to every webhook of the organization that wants it. It runs in the
+// transaction that made the change, so deliveries are queued exactly when
+// the change commits.
+func enqueueWebhooks(ctx context.Context, tx pgx.Tx, messages []stream.Message) error {
+	var slug string
+	if org, ok := OrganizationFromContext(ctx); ok {
+		slug = org.Slug
+	}
+	now := time.Now().UTC()
+
+	batch := &pgx.Batch{}
+	for _, msg := range messages {
+		if !slices.Contains(WebhookEvents, msg.Event) {
+			continue
+		}
+		payload, err := json.Marshal(webhookPayload{Event: msg.Event, Organization: slug, OccurredAt: now, Data: msg.Data})
+		if err != nil {
+			return err
+		}
+		batch.Queue(
+			`INSERT INTO webhook_deliveries (webhook_id, event, payload)
+			 SELECT id, $2, $3 FROM webhooks WHERE organization_id = $1 AND $2 = ANY(events)`,
+			organizationID(ctx), msg.Event, payload,
+		)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// DueDelivery is a delivery the worker should attempt now, with where to
+// send it.
+type DueDelivery struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// ClaimDeliveries picks up to limit pending deliveries that are due, across
+// every organization, and counts an attempt against each. They are not due
+// again until lease has passed, so a worker that dies mid-delivery has its
+// deliveries retried rather than lost, and concurrent workers never claim
+// the same delivery.
+func (r *Repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := r.db.Query(ctx,
+		`WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING `+deliveryColumns+`, w.url, w.secret`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var dd DueDelivery
+		d, err := scanDelivery(rows, &dd.URL, &dd.Secret)
+		if err != nil {
+			return nil, err
+		}
+		dd.WebhookDelivery = *d
+		due = append(due, dd)
+	}
+	return due, rows.Err()
+}
+
+// DeliveryResult is the outcome of one delivery attempt. A delivery that
+// was not delivered is retried after RetryAfter, or given up on when
+// RetryAfter is zero.
+type DeliveryResult struct {
+	Delivered  bool
+	StatusCode *int
+	Error      *string
+	RetryAfter time.Duration
+}
+
+func (r *Repository) RecordDeliveryAttempt(ctx context.Context, deliveryID int64, result DeliveryResult) error {
+	status := DeliveryFailed
+	switch {
+	case result.Delivered:
+		status = DeliveryDelivered
+	case result.RetryAfter > 0:
+		status = DeliveryPending
+	}
+
+	_, err := r.db.Exec(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = $2, last_status_code = $3, last_error = $4,
+		     next_attempt_at = CASE WHEN $2 = 'pending' THEN NOW() + make_interval(secs => $5) ELSE next_attempt_at END,
+		     delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		 WHERE id = $1`,
+		deliveryID, status, result.StatusCode, result.Error, result.RetryAfter.Seconds())
+	return err
+}
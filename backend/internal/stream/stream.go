@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// subscriberBuffer is how many publishes a subscriber may fall behind by
+// before it is dropped.
+const subscriberBuffer = 16
+
+// Message is one Server-Sent Event. Players lists the players it concerns,
+// for subscribers that only follow some of them.
+type Message struct {
+	Event   string
+	Data    []byte
+	Players []int
+}
+
+// Broker fans messages out to everyone subscribed to a topic in this
+// process. The messages of one publish are delivered together. A subscriber
+// that falls behind is dropped rather than holding up the publisher or
+// quietly missing messages: its channel is closed, and it should reconnect
+// and reload whatever it shows.
+type Broker struct {
+	mu     sync.Mutex
+	topics map[string]map[chan []Message]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{topics: make(map[string]map[chan []Message]struct{})}
+}
+
+// Subscribe returns the messages published to topic from now on, a publish
+// at a time, and a function that ends the subscription. The channel is
+// closed if the subscriber falls behind.
+func (b *Broker) Subscribe(topic string) (<-chan []Message, func()) {
+	ch := make(chan []Message, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[chan []Message]struct{})
+	}
+	b.topics[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.remove(topic, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish sends msgs to every subscriber of topic as one delivery, dropping
+// any subscriber with no room left for it.
+func (b *Broker) Publish(topic string, msgs ...Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.topics[topic] {
+		select {
+		case ch <- msgs:
+		default:
+			b.remove(topic, ch)
+			close(ch)
+		}
+	}
+}
+
+// remove forgets a subscriber. b.mu must be held.
+func (b *Broker) remove(topic string, ch chan []Message) {
+	delete(b.topics[topic], ch)
+	if len(b.topics[topic]) == 0 {
+		delete(b.topics, topic)
+	}
+}
+
+// Write encodes msg in the text/event-stream format.
+func Write(w io.Writer, msg Message) error {
+	var sb strings.Builder
+	if msg.Event != "" {
+		fmt.Fprintf(&sb, "event: %s\n", msg.Event)
+	}
+	for _, line := range strings.Split(string(msg.Data), "\n") {
+		fmt.Fprintf(&sb, "data: %s\n", line)
+	}
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,38 @@
+package stream
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPublishDeliversEveryMessageOrDropsTheSubscriber(t *testing.T) {
+	b := NewBroker()
+	messages, unsubscribe := b.Subscribe("topic")
+	defer unsubscribe()
+
+	// One publish larger than the buffer still arrives whole.
+	var many []Message
+	for i := 0; i < 3*subscriberBuffer; i++ {
+		many = append(many, Message{Event: strconv.Itoa(i)})
+	}
+	b.Publish("topic", many...)
+	if batch := <-messages; len(batch) != len(many) {
+		t.Fatalf("received %d messages, want %d", len(batch), len(many))
+	}
+
+	// A subscriber that stops reading is closed, not silently skipped.
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish("topic", Message{Event: strconv.Itoa(i)})
+	}
+	received := 0
+	for range messages {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d publishes before being dropped, want %d", received, subscriberBuffer)
+	}
+
+	// Later publishes, and unsubscribing, leave the dropped channel alone.
+	b.Publish("topic", Message{Event: "after"})
+	unsubscribe()
+}
@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sassoonkuyumcian/foosball-elo/internal/repository"
+)
+
+// Deliveries are retried with exponential backoff, starting at firstRetry,
+// until maxAttempts have been made, which spans roughly an hour.
+const (
+	maxAttempts = 8
+	firstRetry  = 30 * time.Second
+)
+
+const (
+	pollInterval   = 2 * time.Second
+	claimBatchSize = 20
+	requestTimeout = 10 * time.Second
+	// claimLease must outlast an attempt, so a delivery still being sent is
+	// never claimed again.
+	claimLease = 2 * requestTimeout
+	// maxErrorLength bounds the error kept in the delivery log.
+	maxErrorLength = 500
+)
+
+// Sign returns the signature sent in the X-Webhook-Signature header: the
+// hex HMAC-SHA256, keyed with the webhook's secret, of the timestamp sent in
+// X-Webhook-Timestamp, a full stop and the request body. Receivers should
+// compute the same and reject old timestamps to stop replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is how long to wait before retrying a delivery that has failed
+// attempts times.
+func Backoff(attempts int) time.Duration {
+	return firstRetry << (attempts - 1)
+}
+
+// Worker sends queued webhook deliveries in the background.
+type Worker struct {
+	repo   *repository.Repository
+	client *http.Client
+}
+
+func NewWorker(repo *repository.Repository) *Worker {
+	return &Worker{repo: repo, client: newClient()}
+}
+
+// ErrPrivateAddress is returned for webhook URLs that point inside the
+// network the server runs in.
+var ErrPrivateAddress = errors.New("webhooks may only be sent to public addresses")
+
+// newClient returns the client deliveries are sent with. Any organization's
+// admin can choose where webhooks go, so it only connects to public
+// addresses, checked as each connection is made so that DNS cannot point an
+// approved name elsewhere afterwards. It goes direct rather than through a
+// proxy, whose address is all the check would see, and does not follow
+// redirects, which are reported as failed deliveries.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate leaves
+// out.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether ip may be the destination of a webhook.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckURL rejects webhook URLs that name a private address or localhost
+// outright. Names that resolve to private addresses are refused when a
+// delivery is sent.
+func CheckURL(u *url.URL) error {
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrPrivateAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Run delivers due deliveries until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			due, err := w.repo.ClaimDeliveries(ctx, claimBatchSize, claimLease)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+				}
+				break
+			}
+			for _, d := range due {
+				w.deliver(ctx, d)
+			}
+			if len(due) < claimBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, d repository.DueDelivery) {
+	result := w.send(ctx, d)
+	if !result.Delivered && d.Attempts < maxAttempts {
+		result.RetryAfter = Backoff(d.Attempts)
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the delivery to be retried once its claim
+		// lapses rather than recording the cancellation as a failure.
+		return
+	}
+	if !result.Delivered {
+		args := []any{"webhook_id", d.WebhookID, "delivery_id", d.ID, "event", d.Event, "attempts", d.Attempts}
+		if result.StatusCode != nil {
+			args = append(args, "status", *result.StatusCode)
+		}
+		if result.Error != nil {
+			args = append(args, "error", *result.Error)
+		}
+		slog.WarnContext(ctx, "Webhook delivery failed", args...)
+	}
+	if err := w.repo.RecordDeliveryAttempt(ctx, d.ID, result); err != nil {
+		slog.ErrorContext(ctx, "Failed to record webhook delivery", "webhook_id", d.WebhookID, "delivery_id", d.ID, "error", err)
+	}
+}
+
+func (w *Worker) send(ctx context.Context, d repository.DueDelivery) repository.DeliveryResult {
+	fail := func(err error) repository.DeliveryResult {
+		msg := err.Error()
+		if len(msg) > maxErrorLength {
+			msg = msg[:maxErrorLength]
+		}
+		return repository.DeliveryResult{Error: &msg}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return fail(err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "foosball-elo-webhooks")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(d.Secret, timestamp, d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	status := resp.StatusCode
+	if status < 200 || status > 299 {
+		result := fail(fmt.Errorf("unexpected status %s", resp.Status))
+		result.StatusCode = &status
+		return result
+	}
+	return repository.DeliveryResult{Delivered: true, StatusCode: &status}
+}
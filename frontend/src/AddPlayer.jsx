@@ -1,5 +1,6 @@
 import { useState, useEffect } from 'react'
 import Navigation from './Navigation'
+import { authHeaders, checkAuth } from './auth'
 import './App.css'
 
 const API_URL = import.meta.env.DEV ? 'http://localhost:8080/api' : window.location.origin + '/api'
@@ -23,18 +24,24 @@ function AddPlayer() {
   const createPlayer = async (e) => {
     e.preventDefault()
     if (!newPlayerName.trim()) return
-    await fetch(`${API_URL}/players`, {
+    const res = await fetch(`${API_URL}/players`, {
       method: 'POST',
-      headers: { 'Content-Type': 'application/json' },
+      headers: { 'Content-Type': 'application/json', ...authHeaders() },
       body: JSON.stringify({ name: newPlayerName })
     })
+    if (!checkAuth(res)) return
     setNewPlayerName('')
     fetchPlayers()
   }
 
-  const deletePlayer = async (id) => {
+  const deletePlayer = async (player) => {
     if (!confirm('Delete this player?')) return
-    await fetch(`${API_URL}/players/${id}`, { method: 'DELETE' })
+    const res = await fetch(`${API_URL}/players/${player.id}`, {
+      method: 'DELETE',
+      headers: { 'If-Match': `"${player.version}"`, ...authHeaders() }
+    })
+    if (!checkAuth(res)) return
+    if (res.status === 412) alert('This player was changed by someone else. Reloading.')
     fetchPlayers()
   }
 
@@ -48,13 +55,15 @@ function AddPlayer() {
     setEditName('')
   }
 
-  const saveEdit = async (id) => {
+  const saveEdit = async (player) => {
     if (!editName.trim()) return
-    await fetch(`${API_URL}/players/${id}`, {
+    const res = await fetch(`${API_URL}/players/${player.id}`, {
       method: 'PUT',
-      headers: { 'Content-Type': 'application/json' },
+      headers: { 'Content-Type': 'application/json', 'If-Match': `"${player.version}"`, ...authHeaders() },
       body: JSON.stringify({ name: editName })
     })
+    if (!checkAuth(res)) return
+    if (res.status === 412) alert('This player was changed by someone else. Reloading.')
     setEditingId(null)
     setEditName('')
     fetchPlayers()
@@ -108,13 +117,13 @@ function AddPlayer() {
                   <td>
                     {editingId === p.id ? (
                       <>
-                        <button onClick={() => saveEdit(p.id)}>Save</button>
+                        <button onClick={() => saveEdit(p)}>Save</button>
                         <button onClick={cancelEdit}>Cancel</button>
                       </>
                     ) : (
                       <>
                         <button className="edit" onClick={() => startEdit(p)}>Edit</button>
-                        <button className="delete" onClick={() => deletePlayer(p.id)}>Delete</button>
+                        <button className="delete" onClick={() => deletePlayer(p)}>Delete</button>
                       </>
                     )}
                   </td>
@@ -1,5 +1,6 @@
 import { useState, useEffect } from 'react'
 import Navigation from './Navigation'
+import { authHeaders, checkAuth } from './auth'
 import './App.css'
 
 const API_URL = import.meta.env.DEV ? 'http://localhost:8080/api' : window.location.origin + '/api'
@@ -41,7 +42,7 @@ function Game() {
   const fetchGames = async () => {
     const res = await fetch(`${API_URL}/games`)
     const data = await res.json()
-    setGames(data || [])
+    setGames(data.games || [])
   }
 
   const filterPlayers = (searchTerm, excludeIds = []) => {
@@ -71,9 +72,9 @@ function Game() {
     e.preventDefault()
     if (team1Players.length === 0 || team2Players.length === 0) return
 
-    await fetch(`${API_URL}/games`, {
+    const res = await fetch(`${API_URL}/games`, {
       method: 'POST',
-      headers: { 'Content-Type': 'application/json' },
+      headers: { 'Content-Type': 'application/json', 'Idempotency-Key': crypto.randomUUID(), ...authHeaders() },
       body: JSON.stringify({
         game_type: gameType,
         teams: [
@@ -82,6 +83,7 @@ function Game() {
         ]
       })
     })
+    if (!checkAuth(res)) return
 
     setTeam1Players([])
     setTeam2Players([])
@@ -91,11 +93,14 @@ function Game() {
     fetchGames()
   }
 
-  const deleteGame = async (gameId) => {
+  const deleteGame = async (game) => {
     if (!confirm('Undo this game? This will revert all rating changes.')) return
-    await fetch(`${API_URL}/games/${gameId}`, {
-      method: 'DELETE'
+    const res = await fetch(`${API_URL}/games/${game.id}`, {
+      method: 'DELETE',
+      headers: { 'If-Match': `"${game.version}"`, ...authHeaders() }
     })
+    if (!checkAuth(res)) return
+    if (res.status === 412) alert('This game was changed by someone else. Reloading.')
     fetchGames()
   }
 
@@ -103,15 +108,17 @@ function Game() {
     setEditingGame(game)
   }
 
-  const updateGame = async (gameId, newWinner) => {
-    await fetch(`${API_URL}/games/${gameId}`, {
+  const updateGame = async (game, newWinner) => {
+    const res = await fetch(`${API_URL}/games/${game.id}`, {
       method: 'PUT',
-      headers: { 'Content-Type': 'application/json' },
+      headers: { 'Content-Type': 'application/json', 'If-Match': `"${game.version}"`, ...authHeaders() },
       body: JSON.stringify({
         team1_score: newWinner === 'team1' ? 10 : 0,
         team2_score: newWinner === 'team2' ? 10 : 0
       })
     })
+    if (!checkAuth(res)) return
+    if (res.status === 412) alert('This game was changed by someone else. Reloading.')
     setEditingGame(null)
     fetchGames()
   }
@@ -268,7 +275,7 @@ function Game() {
                   </button>
                   <button
                     className="delete"
-                    onClick={() => deleteGame(game.id)}
+                    onClick={() => deleteGame(game)}
                     style={{marginLeft: '10px'}}
                   >
                     Undo
@@ -298,7 +305,7 @@ function Game() {
                     <button
                       onClick={() => {
                         const newWinner = document.querySelector(`input[name="winner-${game.id}"]:checked`).value
-                        updateGame(game.id, newWinner)
+                        updateGame(game, newWinner)
                       }}
                       style={{background: '#27ae60', marginRight: '10px'}}
                     >
@@ -9,9 +9,24 @@ function Leaderboard() {
   const [leaderboard, setLeaderboard] = useState([])
 
   useEffect(() => {
-    fetchLeaderboard()
-    const interval = setInterval(fetchLeaderboard, 30000) // Refresh every 30s
-    return () => clearInterval(interval)
+    // Refetch whenever the server reports a change. One game moves several
+    // players at once, so changes arriving together share a refetch. The
+    // stream also opens again after reconnecting, which covers anything
+    // missed meanwhile.
+    let timer
+    const refresh = () => {
+      clearTimeout(timer)
+      timer = setTimeout(fetchLeaderboard, 200)
+    }
+    const events = new EventSource(`${API_URL}/stream`)
+    events.onopen = refresh
+    for (const change of ['game_recorded', 'game_corrected', 'game_deleted', 'player_created', 'rank_changed']) {
+      events.addEventListener(change, refresh)
+    }
+    return () => {
+      clearTimeout(timer)
+      events.close()
+    }
   }, [])
 
   const fetchLeaderboard = async () => {
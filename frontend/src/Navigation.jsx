@@ -1,4 +1,5 @@
 import { Link, useLocation } from 'react-router-dom'
+import { promptForApiKey } from './auth'
 
 function Navigation() {
   const location = useLocation()
@@ -22,6 +23,7 @@ function Navigation() {
         <>
           <Link to="/games"><button style={buttonStyle('/games')}>Games</button></Link>
           <Link to="/add-player"><button style={buttonStyle('/add-player')}>Players</button></Link>
+          <button onClick={promptForApiKey}>API key</button>
         </>
       )}
     </div>
@@ -0,0 +1,27 @@
+const STORAGE_KEY = 'apiKey'
+
+// Headers that authenticate admin requests with the API key saved in this
+// browser, if any.
+export function authHeaders() {
+  const key = localStorage.getItem(STORAGE_KEY)
+  return key ? { Authorization: `Bearer ${key}` } : {}
+}
+
+export function promptForApiKey() {
+  const key = prompt('API key (leave empty to forget the saved key)', '')
+  if (key === null) return
+  if (key.trim()) {
+    localStorage.setItem(STORAGE_KEY, key.trim())
+  } else {
+    localStorage.removeItem(STORAGE_KEY)
+  }
+}
+
+// Explains a request refused for lack of a suitable API key.
+export function checkAuth(res) {
+  if (res.status === 401 || res.status === 403) {
+    alert('You need an API key with permission to do that. Set one with the "API key" button.')
+    return false
+  }
+  return true
+}
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_type_aggregate ON events(event_type, aggregate_id);
+
+-- Seed the ledger from data recorded before it existed so projections can be
+-- rebuilt without losing history.
+INSERT INTO events (event_type, aggregate_id, payload, actor, created_at)
+SELECT event_type, aggregate_id, payload, 'migration', created_at
+FROM (
+    SELECT 'PlayerCreated' AS event_type, p.id AS aggregate_id, 0 AS kind, p.created_at,
+           jsonb_build_object(
+               'player_id', p.id,
+               'name', p.name,
+               'created_at', to_char(p.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+           ) AS payload
+    FROM players p
+    UNION ALL
+    SELECT 'GameRecorded', g.id, 1, g.created_at,
+           jsonb_build_object(
+               'game_id', g.id,
+               'game_type', g.game_type,
+               'created_at', to_char(g.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+               'teams', jsonb_build_array(
+                   jsonb_build_object(
+                       'player_ids', COALESCE((SELECT jsonb_agg(gp.player_id ORDER BY gp.id) FROM game_participants gp WHERE gp.game_id = g.id AND gp.team = 1), '[]'::jsonb),
+                       'score', COALESCE((SELECT MAX(gp.score) FROM game_participants gp WHERE gp.game_id = g.id AND gp.team = 1), 0)
+                   ),
+                   jsonb_build_object(
+                       'player_ids', COALESCE((SELECT jsonb_agg(gp.player_id ORDER BY gp.id) FROM game_participants gp WHERE gp.game_id = g.id AND gp.team = 2), '[]'::jsonb),
+                       'score', COALESCE((SELECT MAX(gp.score) FROM game_participants gp WHERE gp.game_id = g.id AND gp.team = 2), 0)
+                   )
+               )
+           )
+    FROM games g
+) history
+WHERE NOT EXISTS (SELECT 1 FROM events)
+ORDER BY created_at, kind, aggregate_id;
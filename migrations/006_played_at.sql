@@ -0,0 +1,6 @@
+ALTER TABLE games ADD COLUMN IF NOT EXISTS played_at TIMESTAMP;
+UPDATE games SET played_at = created_at WHERE played_at IS NULL;
+ALTER TABLE games ALTER COLUMN played_at SET DEFAULT NOW();
+ALTER TABLE games ALTER COLUMN played_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_games_played_at ON games(played_at DESC, id DESC);
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    rating_system VARCHAR(50) NOT NULL DEFAULT 'elo' CHECK (rating_system IN ('elo', 'margin_elo')),
+    k_factor INTEGER NOT NULL DEFAULT 32 CHECK (k_factor > 0),
+    game_formats TEXT[] NOT NULL DEFAULT '{singles,doubles}',
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Everything recorded before organizations existed belongs to the default one.
+INSERT INTO organizations (slug, name) VALUES ('default', 'Default') ON CONFLICT (slug) DO NOTHING;
+
+ALTER TABLE players ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE games ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+
+UPDATE players SET organization_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE organization_id IS NULL;
+UPDATE games SET organization_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE organization_id IS NULL;
+UPDATE events SET organization_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE organization_id IS NULL;
+UPDATE audit_log SET organization_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE organization_id IS NULL;
+UPDATE idempotency_keys SET organization_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE organization_id IS NULL;
+
+ALTER TABLE players ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE games ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE events ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE audit_log ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN organization_id SET NOT NULL;
+
+-- The same key may be used by clients of different organizations.
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pkey,
+    ADD PRIMARY KEY (organization_id, key, method, path);
+
+ALTER TABLE audit_log
+    DROP CONSTRAINT IF EXISTS audit_log_entity_type_check,
+    ADD CONSTRAINT audit_log_entity_type_check CHECK (entity_type IN ('player', 'game', 'organization'));
+
+CREATE INDEX IF NOT EXISTS idx_players_organization_id ON players(organization_id);
+CREATE INDEX IF NOT EXISTS idx_games_organization_played_at ON games(organization_id, played_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_organization_id ON events(organization_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_organization_id ON audit_log(organization_id);
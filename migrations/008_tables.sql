@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS tables (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT '',
+    k_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (k_multiplier > 0),
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (organization_id, name)
+);
+
+ALTER TABLE games ADD COLUMN IF NOT EXISTS table_id INTEGER REFERENCES tables(id);
+
+CREATE INDEX IF NOT EXISTS idx_games_table_id ON games(table_id);
+
+ALTER TABLE audit_log
+    DROP CONSTRAINT IF EXISTS audit_log_entity_type_check,
+    ADD CONSTRAINT audit_log_entity_type_check CHECK (entity_type IN ('player', 'game', 'organization', 'table'));
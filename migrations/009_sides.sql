@@ -0,0 +1 @@
+ALTER TABLE game_participants ADD COLUMN IF NOT EXISTS side VARCHAR(20);
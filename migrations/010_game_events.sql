@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS game_events (
+    id SERIAL PRIMARY KEY,
+    game_id INTEGER NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    player_id INTEGER REFERENCES players(id) ON DELETE SET NULL,
+    team INTEGER NOT NULL CHECK (team IN (1, 2)),
+    rod VARCHAR(20) CHECK (rod IN ('goalie', 'defense', 'midfield', 'attack')),
+    own_goal BOOLEAN NOT NULL DEFAULT FALSE,
+    elapsed_seconds INTEGER CHECK (elapsed_seconds >= 0),
+    UNIQUE (game_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_events_player_id ON game_events(player_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS live_games (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    game_type VARCHAR(20) NOT NULL CHECK (game_type IN ('singles', 'doubles')),
+    table_id INTEGER REFERENCES tables(id),
+    teams JSONB NOT NULL,
+    goals JSONB NOT NULL DEFAULT '[]',
+    version INTEGER NOT NULL DEFAULT 1,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_live_games_organization_id ON live_games(organization_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS chat_handles (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    handle VARCHAR(255) NOT NULL,
+    player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (organization_id, handle)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_handles_player_id ON chat_handles(player_id);
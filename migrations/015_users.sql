@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    username VARCHAR(50) NOT NULL,
+    password_hash VARCHAR(60) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'recorder' CHECK (role IN ('viewer', 'recorder', 'admin')),
+    player_id INTEGER REFERENCES players(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT users_username_key UNIQUE (organization_id, username),
+    CONSTRAINT users_player_id_key UNIQUE (player_id)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+ALTER TABLE games ADD COLUMN IF NOT EXISTS submitted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE audit_log
+    DROP CONSTRAINT IF EXISTS audit_log_entity_type_check,
+    ADD CONSTRAINT audit_log_entity_type_check CHECK (entity_type IN ('player', 'game', 'organization', 'table', 'webhook', 'api_key', 'user'));
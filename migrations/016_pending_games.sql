@@ -0,0 +1,24 @@
+ALTER TABLE organizations
+    ADD COLUMN IF NOT EXISTS require_confirmation BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS confirmation_hours INTEGER NOT NULL DEFAULT 48 CHECK (confirmation_hours > 0);
+
+-- Games waiting for a player on the losing team to confirm them. They are
+-- kept once confirmed or rejected, with game_id pointing at the game a
+-- confirmation recorded.
+CREATE TABLE IF NOT EXISTS pending_games (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    request JSONB NOT NULL,
+    player_ids INTEGER[] NOT NULL,
+    confirmable_by INTEGER[] NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'rejected')),
+    submitted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    submitted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    confirm_by TIMESTAMP NOT NULL,
+    resolved_by VARCHAR(255),
+    resolved_at TIMESTAMP,
+    game_id INTEGER REFERENCES games(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_games_organization_id ON pending_games(organization_id, status);
+CREATE INDEX IF NOT EXISTS idx_pending_games_confirm_by ON pending_games(confirm_by) WHERE status = 'pending';
@@ -0,0 +1,19 @@
+-- Challenges to recorded games. game_id has no foreign key so disputes
+-- outlive the games voided to resolve them.
+CREATE TABLE IF NOT EXISTS game_disputes (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    game_id INTEGER NOT NULL,
+    comment TEXT NOT NULL,
+    opened_by VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'upheld', 'corrected', 'voided')),
+    resolution_comment TEXT,
+    resolved_by VARCHAR(255),
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_disputes_organization_id ON game_disputes(organization_id, status);
+
+-- A game has at most one open dispute at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_game_disputes_open ON game_disputes(game_id) WHERE status = 'open';
@@ -0,0 +1,5 @@
+-- Live games belong to their organization and go when it does, like
+-- everything else scoped to one.
+ALTER TABLE live_games
+    DROP CONSTRAINT IF EXISTS live_games_organization_id_fkey,
+    ADD CONSTRAINT live_games_organization_id_fkey FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE;
@@ -0,0 +1,9 @@
+-- Keys belong to the caller who sent them, so two clients that happen to
+-- pick the same key do not see each other's responses. A reservation whose
+-- request never finished may be taken over once locked_until has passed.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS caller VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP NOT NULL DEFAULT NOW();
+
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pkey,
+    ADD PRIMARY KEY (organization_id, caller, key, method, path);
@@ -0,0 +1,14 @@
+-- What callers without a key or session may do is up to each organization.
+-- The default organization keeps the public leaderboard it has always had;
+-- the rest, which never chose to be public, are closed to anonymous callers.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'organizations' AND column_name = 'anonymous_role'
+    ) THEN
+        ALTER TABLE organizations ADD COLUMN anonymous_role VARCHAR(20) NOT NULL DEFAULT 'none'
+            CHECK (anonymous_role IN ('none', 'viewer', 'recorder', 'admin'));
+        UPDATE organizations SET anonymous_role = 'viewer' WHERE slug = 'default';
+    END IF;
+END $$;
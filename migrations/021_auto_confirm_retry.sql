@@ -0,0 +1,3 @@
+-- A pending game that fails to confirm automatically is set aside until
+-- auto_confirm_retry_at, so it does not hold up the overdue games behind it.
+ALTER TABLE pending_games ADD COLUMN IF NOT EXISTS auto_confirm_retry_at TIMESTAMP;
@@ -0,0 +1,6 @@
+-- Deleting a game now closes its open dispute. Close the ones left open by
+-- games deleted before that, which could no longer be resolved.
+UPDATE game_disputes d
+SET status = 'voided', resolved_by = 'system:migration', resolved_at = NOW()
+WHERE d.status = 'open'
+  AND NOT EXISTS (SELECT 1 FROM games g WHERE g.id = d.game_id AND g.organization_id = d.organization_id);